@@ -0,0 +1,376 @@
+package main
+
+import (
+  "math/bits"
+  "reflect"
+)
+
+// ArchetypeStorage is an alternative to ComponentStore which groups entities by the
+// set of components they have (their "archetype".)
+//
+// Each archetype stores its entities in a table with one column per component
+// (struct of arrays), so that a system can iterate over a few components of many
+// entities by walking tightly packed columns, without any per-entity lookups:
+//
+//   var storage ArchetypeStorage
+//   storage.Init(&world.Ents)
+//   position := storage.RegisterComponent("position", func() ComponentArray { return &Vec2Array{} })
+//   velocity := storage.RegisterComponent("velocity", func() ComponentArray { return &Vec2Array{} })
+//   storage.Set(e, position, Vec2{1, 2})
+//   ...
+//   for _, a := range storage.Match(position.Mask() | velocity.Mask(), 0, nil) {
+//     pos := *a.Column(position).(*Vec2Array)
+//     vel := *a.Column(velocity).(*Vec2Array)
+//     for i := range pos {
+//       pos[i] = pos[i].Add(vel[i].Mul(dt))
+//     }
+//   }
+//
+// The trade-off is that adding or removing a component moves all of the entity's
+// component values to another archetype, which is more expensive than with a
+// ComponentStore. Use it for data that is iterated often and changes shape rarely.
+// Columns that implement ArchetypeColumn are copied without reflection, which makes
+// such moves cheaper (see archetype_test.go for benchmarks.)
+//
+// Like ComponentStore, rows are removed by moving the last row into the hole, so
+// pointers returned by Get and columns returned by Archetype.Column are only valid until
+// the next structural change (Add, Set of a new component, Remove or DestroyEnt.)
+//
+type ArchetypeStorage struct {
+  em         *EntManager
+  components []archetypeComponent // indexed by ComponentID
+  root       *Archetype           // the empty archetype; holds no entities
+  archetypes []*Archetype         // all archetypes, including root
+  byMask     map[ComponentMask]*Archetype
+  locs       []archetypeLoc       // EntManager.Index(e) => location
+}
+
+// ComponentID identifies a component registered with an ArchetypeStorage
+type ComponentID uint8
+
+// ComponentMask is a set of ComponentIDs
+type ComponentMask uint64
+
+// MaxArchetypeComponents is the maximum number of components of an ArchetypeStorage
+const MaxArchetypeComponents = 64
+
+func (c ComponentID) Mask() ComponentMask { return ComponentMask(1) << c }
+
+func (m ComponentMask) Has(c ComponentID) bool { return m & c.Mask() != 0 }
+
+// ArchetypeColumn is optionally implemented by the ComponentArray of a component to
+// copy values between archetypes without reflection, e.g.
+//
+//   func (a *Vec2Array) AppendFrom(src ComponentArray, i int) {
+//     *a = append(*a, (*src.(*Vec2Array))[i])
+//   }
+//
+type ArchetypeColumn interface {
+  ComponentArray
+  AppendFrom(src ComponentArray, i int) // appends a copy of value i of src (same type)
+}
+
+type archetypeComponent struct {
+  name      string
+  newColumn func() ComponentArray
+}
+
+type archetypeLoc struct {
+  archetype *Archetype // nil if the entity has no components
+  row       int32
+}
+
+// Archetype is a table of all entities that have exactly the same set of components
+type Archetype struct {
+  mask    ComponentMask
+  ents    []Ent
+  columns []ComponentArray // one per bit in mask, in ComponentID order
+  add     map[ComponentID]*Archetype // cached transitions
+  remove  map[ComponentID]*Archetype
+}
+
+// Mask returns the set of components of entities in a
+func (a *Archetype) Mask() ComponentMask { return a.mask }
+
+// Len returns the number of entities in a
+func (a *Archetype) Len() int { return len(a.ents) }
+
+// Ents returns the entities of a; Ents()[i] owns row i of every column
+func (a *Archetype) Ents() []Ent { return a.ents }
+
+// Column returns the values of component c, or nil if a does not have c
+func (a *Archetype) Column(c ComponentID) ComponentArray {
+  if !a.mask.Has(c) {
+    return nil
+  }
+  return a.columns[a.columnIndex(c)]
+}
+
+func (a *Archetype) columnIndex(c ComponentID) int {
+  // number of components in mask with a lower ID than c
+  return bits.OnesCount64(uint64(a.mask & (c.Mask() - 1)))
+}
+
+func (a *Archetype) removeRow(row int) Ent {
+  last := len(a.ents) - 1
+  moved := NilEnt
+  if row != last {
+    moved = a.ents[last]
+    a.ents[row] = moved
+    for _, col := range a.columns {
+      col.Move(row, last)
+    }
+  }
+  a.ents = a.ents[:last]
+  for _, col := range a.columns {
+    col.Truncate(last)
+  }
+  return moved
+}
+
+// -----------------------------------------------------------------------------
+
+// Init initializes the storage. em is used to check that entities are alive.
+func (s *ArchetypeStorage) Init(em *EntManager) {
+  s.em = em
+  s.components = s.components[:0]
+  s.root = &Archetype{}
+  s.archetypes = append(s.archetypes[:0], s.root)
+  s.byMask = map[ComponentMask]*Archetype{ 0: s.root }
+  s.locs = nil
+}
+
+// RegisterComponent adds a component to the storage and returns its ID.
+// newColumn is called to create the column of the component in each archetype.
+func (s *ArchetypeStorage) RegisterComponent(name string, newColumn func() ComponentArray) ComponentID {
+  if len(s.components) == MaxArchetypeComponents {
+    panicf("ArchetypeStorage.RegisterComponent: too many components (max %d)",
+      MaxArchetypeComponents)
+  }
+  s.components = append(s.components, archetypeComponent{ name: name, newColumn: newColumn })
+  return ComponentID(len(s.components) - 1)
+}
+
+// ComponentName returns the name c was registered with
+func (s *ArchetypeStorage) ComponentName(c ComponentID) string {
+  return s.components[c].name
+}
+
+// Archetypes returns all archetypes, in the order they were created.
+// The first archetype is always the empty archetype.
+func (s *ArchetypeStorage) Archetypes() []*Archetype {
+  return s.archetypes
+}
+
+// Match appends archetypes that have all components in with and none in without to
+// buf and returns the result.
+func (s *ArchetypeStorage) Match(with, without ComponentMask, buf []*Archetype) []*Archetype {
+  for _, a := range s.archetypes {
+    if a.mask & with == with && a.mask & without == 0 && len(a.ents) > 0 {
+      buf = append(buf, a)
+    }
+  }
+  return buf
+}
+
+// Mask returns the set of components of e
+func (s *ArchetypeStorage) Mask(e Ent) ComponentMask {
+  if a, _ := s.lookup(e); a != nil {
+    return a.mask
+  }
+  return 0
+}
+
+// Has returns true if e has component c
+func (s *ArchetypeStorage) Has(e Ent, c ComponentID) bool {
+  return s.Mask(e).Has(c)
+}
+
+// Get returns a pointer to e's value of component c, or nil if e does not have c.
+// The pointer is only valid until the next structural change.
+func (s *ArchetypeStorage) Get(e Ent, c ComponentID) interface{} {
+  if DEBUG {
+    checkAlive(s.em, e, 0)
+  }
+  a, row := s.lookup(e)
+  if a == nil || !a.mask.Has(c) {
+    return nil
+  }
+  return a.columns[a.columnIndex(c)].Ptr(row)
+}
+
+// Add adds a zero value of component c to e, moving e to another archetype.
+// Returns e's archetype and row. Does nothing if e already has c.
+func (s *ArchetypeStorage) Add(e Ent, c ComponentID) (*Archetype, int) {
+  if int(c) >= len(s.components) {
+    panicf("ArchetypeStorage.Add: unknown component %d", c)
+  }
+  a, row := s.lookup(e)
+  if a == nil {
+    if !s.em.IsAlive(e) {
+      panicf("ArchetypeStorage.Add: entity %#v is not alive", e)
+    }
+    a = s.root
+  } else if a.mask.Has(c) {
+    return a, row
+  }
+  dst := a.add[c]
+  if dst == nil {
+    dst = s.archetype(a.mask | c.Mask())
+    if a.add == nil {
+      a.add = make(map[ComponentID]*Archetype)
+    }
+    a.add[c] = dst
+  }
+  return dst, s.move(e, a, row, dst)
+}
+
+// Set adds or replaces e's value of component c.
+// value must be of the component's type, e.g. Vec2 for a column of type *Vec2Array.
+func (s *ArchetypeStorage) Set(e Ent, c ComponentID, value interface{}) {
+  if DEBUG {
+    checkAlive(s.em, e, 0)
+  }
+  a, row := s.Add(e, c)
+  dst := reflect.ValueOf(a.columns[a.columnIndex(c)].Ptr(row)).Elem()
+  src := reflect.ValueOf(value)
+  if src.Type() != dst.Type() {
+    panicf("ArchetypeStorage.Set: value of type %s is not a %s", src.Type(), dst.Type())
+  }
+  dst.Set(src)
+}
+
+// Remove removes component c from e, moving e to another archetype.
+// Returns false if e did not have c.
+func (s *ArchetypeStorage) Remove(e Ent, c ComponentID) bool {
+  a, row := s.lookup(e)
+  if a == nil || !a.mask.Has(c) {
+    return false
+  }
+  dst := a.remove[c]
+  if dst == nil {
+    dst = s.archetype(a.mask &^ c.Mask())
+    if a.remove == nil {
+      a.remove = make(map[ComponentID]*Archetype)
+    }
+    a.remove[c] = dst
+  }
+  s.move(e, a, row, dst)
+  return true
+}
+
+// DestroyEnt removes all of e's components. Implements System.
+func (s *ArchetypeStorage) DestroyEnt(e Ent) {
+  if a, row := s.lookupAny(e); a != nil {
+    s.move(e, a, row, s.root)
+  }
+}
+
+// lookup returns the archetype and row of e, or nil if e has no components or is not
+// alive.
+func (s *ArchetypeStorage) lookup(e Ent) (*Archetype, int) {
+  if !s.em.IsAlive(e) {
+    return nil, -1
+  }
+  return s.lookupAny(e)
+}
+
+// lookupAny is like lookup but does not check if e is alive
+func (s *ArchetypeStorage) lookupAny(e Ent) (*Archetype, int) {
+  x := int(s.em.Index(e))
+  if x >= len(s.locs) {
+    return nil, -1
+  }
+  loc := s.locs[x]
+  // Note: comparing the full Ent makes sure that an entity reusing the index slot
+  // of an older entity is not mistaken for the older one.
+  if loc.archetype == nil || loc.archetype.ents[loc.row] != e {
+    return nil, -1
+  }
+  return loc.archetype, int(loc.row)
+}
+
+// archetype returns the archetype for mask, creating it if needed
+func (s *ArchetypeStorage) archetype(mask ComponentMask) *Archetype {
+  if a := s.byMask[mask]; a != nil {
+    return a
+  }
+  a := &Archetype{ mask: mask }
+  for m := uint64(mask); m != 0; m &= m - 1 {
+    c := bits.TrailingZeros64(m)
+    a.columns = append(a.columns, s.components[c].newColumn())
+  }
+  s.byMask[mask] = a
+  s.archetypes = append(s.archetypes, a)
+  return a
+}
+
+// move moves e from row in src (src may be the root archetype) to dst, copying the
+// values of components that are in both. Returns the row of e in dst.
+func (s *ArchetypeStorage) move(e Ent, src *Archetype, row int, dst *Archetype) int {
+  x := int(s.em.Index(e))
+  if x >= len(s.locs) {
+    if x < cap(s.locs) {
+      s.locs = s.locs[:x+1]
+    } else {
+      locs := make([]archetypeLoc, x+1, max(x+1, cap(s.locs)*2))
+      copy(locs, s.locs)
+      s.locs = locs
+    }
+  } else if loc := s.locs[x]; loc.archetype != nil && src == s.root {
+    // the slot is held by a dead entity with an older generation
+    s.locs[x] = archetypeLoc{}
+    if moved := loc.archetype.removeRow(int(loc.row)); moved != NilEnt {
+      s.locs[s.em.Index(moved)].row = loc.row
+    }
+  }
+
+  newRow := -1
+  if dst != s.root {
+    newRow = len(dst.ents)
+    dst.ents = append(dst.ents, e)
+    // append values of components in both src and dst, and zero values for the rest
+    j := 0 // column index in dst
+    for m := uint64(dst.mask); m != 0; m &= m - 1 {
+      c := ComponentID(bits.TrailingZeros64(m))
+      col := dst.columns[j]
+      j++
+      if !src.mask.Has(c) {
+        col.Append()
+        continue
+      }
+      from := src.columns[src.columnIndex(c)]
+      if ac, ok := col.(ArchetypeColumn); ok {
+        ac.AppendFrom(from, row)
+      } else {
+        col.Append()
+        v := reflect.ValueOf(from.Ptr(row)).Elem()
+        reflect.ValueOf(col.Ptr(newRow)).Elem().Set(v)
+      }
+    }
+    s.locs[x] = archetypeLoc{ archetype: dst, row: int32(newRow) }
+  } else {
+    s.locs[x] = archetypeLoc{}
+  }
+
+  if src != s.root {
+    if moved := src.removeRow(row); moved != NilEnt {
+      s.locs[s.em.Index(moved)].row = int32(row)
+    }
+  }
+  return newRow
+}
+
+// -----------------------------------------------------------------------------
+
+// Vec2Array is a ComponentArray of Vec2 values, e.g. for positions and velocities
+type Vec2Array []Vec2
+
+func (a *Vec2Array) Append()               { *a = append(*a, Vec2{}) }
+func (a *Vec2Array) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *Vec2Array) Truncate(n int)        { *a = (*a)[:n] }
+func (a *Vec2Array) Ptr(i int) interface{} { return &(*a)[i] }
+
+func (a *Vec2Array) AppendFrom(src ComponentArray, i int) {
+  *a = append(*a, (*src.(*Vec2Array))[i])
+}
@@ -0,0 +1,214 @@
+package main
+
+import "testing"
+
+// benchEnts is the number of entities of benchmarks
+const benchEnts = 100000
+
+// vec2Values is a ComponentArray without AppendFrom, for testing the reflect fallback
+type vec2Values []Vec2
+
+func (a *vec2Values) Append()               { *a = append(*a, Vec2{}) }
+func (a *vec2Values) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *vec2Values) Truncate(n int)        { *a = (*a)[:n] }
+func (a *vec2Values) Ptr(i int) interface{} { return &(*a)[i] }
+
+func TestArchetypeMoveKeepsValues(t *testing.T) {
+  var em EntManager
+  em.Init()
+  var s ArchetypeStorage
+  s.Init(&em)
+  position := s.RegisterComponent("position", func() ComponentArray { return &Vec2Array{} })
+  label := s.RegisterComponent("label", func() ComponentArray { return &vec2Values{} })
+  velocity := s.RegisterComponent("velocity", func() ComponentArray { return &Vec2Array{} })
+
+  ents := make([]Ent, 10)
+  for i := range ents {
+    e := em.Alloc()
+    ents[i] = e
+    s.Set(e, position, Vec2{ float32(i), 0 })
+    s.Set(e, label, Vec2{ 0, float32(i) })
+  }
+  for i, e := range ents {
+    if i % 2 == 0 {
+      s.Set(e, velocity, Vec2{ 1, 1 })
+    }
+  }
+  s.Remove(ents[4], label)
+  for i, e := range ents {
+    if p := s.Get(e, position).(*Vec2); *p != (Vec2{ float32(i), 0 }) {
+      t.Errorf("entity %d: position %v", i, *p)
+    }
+    l, _ := s.Get(e, label).(*Vec2)
+    if (i == 4) != (l == nil) || (l != nil && *l != (Vec2{ 0, float32(i) })) {
+      t.Errorf("entity %d: label %v", i, l)
+    }
+    if s.Has(e, velocity) != (i % 2 == 0) {
+      t.Errorf("entity %d: unexpected velocity", i)
+    }
+  }
+}
+
+// -----------------------------------------------------------------------------
+// Benchmarks of ArchetypeStorage against ComponentStore (sparse sets), each with
+// benchEnts entities:
+//
+//   Iterate    integrate velocity and acceleration into position of all entities,
+//              each component in its own store or column
+//   Join       move entities that have both a position and a velocity; half do
+//   AddRemove  add and remove a component of one entity
+
+func BenchmarkSparseSetIterate(b *testing.B) {
+  w := newTestWorld()
+  var position, velocity, acceleration benchVec2Store
+  position.Init(w)
+  velocity.Init(w)
+  acceleration.Init(w)
+  for i := 0; i < benchEnts; i++ {
+    e := w.Ents.Alloc()
+    position.Add(e)
+    velocity.data[velocity.Add(e)] = Vec2{ 1, 1 }
+    acceleration.data[acceleration.Add(e)] = Vec2{ 0, -1 }
+  }
+  dt := float32(1.0 / 60.0)
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    for i, e := range position.Ents() {
+      v := &velocity.data[velocity.Index(e)]
+      *v = v.Add(acceleration.data[acceleration.Index(e)].Mul(dt))
+      position.data[i] = position.data[i].Add(v.Mul(dt))
+    }
+  }
+}
+
+func BenchmarkArchetypeIterate(b *testing.B) {
+  s, ids := newBenchArchetypeStorage(b, 3)
+  position, velocity, acceleration := ids[0], ids[1], ids[2]
+  with := position.Mask() | velocity.Mask() | acceleration.Mask()
+  dt := float32(1.0 / 60.0)
+  var match []*Archetype
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    for _, a := range s.Match(with, 0, match[:0]) {
+      pos := *a.Column(position).(*Vec2Array)
+      vel := *a.Column(velocity).(*Vec2Array)
+      acc := *a.Column(acceleration).(*Vec2Array)
+      for i := range pos {
+        vel[i] = vel[i].Add(acc[i].Mul(dt))
+        pos[i] = pos[i].Add(vel[i].Mul(dt))
+      }
+    }
+  }
+}
+
+func BenchmarkSparseSetJoin(b *testing.B) {
+  w := newTestWorld()
+  var position, velocity benchVec2Store
+  position.Init(w)
+  velocity.Init(w)
+  for i := 0; i < benchEnts; i++ {
+    e := w.Ents.Alloc()
+    position.data[position.Add(e)] = Vec2{ float32(i), 0 }
+    if i % 2 == 0 {
+      velocity.data[velocity.Add(e)] = Vec2{ 1, 1 }
+    }
+  }
+  q := w.Query(&position.ComponentStore, &velocity.ComponentStore)
+  dt := float32(1.0 / 60.0)
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    for q.Reset(); q.Next(); {
+      p := &position.data[q.Index(0)]
+      *p = p.Add(velocity.data[q.Index(1)].Mul(dt))
+    }
+  }
+}
+
+func BenchmarkArchetypeJoin(b *testing.B) {
+  s, ids := newBenchArchetypeStorage(b, 1)
+  position := ids[0]
+  velocity := s.RegisterComponent("velocity", func() ComponentArray { return &Vec2Array{} })
+  for i, a := range s.Match(position.Mask(), 0, nil) {
+    for row, e := range a.Ents() {
+      if (i + row) % 2 == 0 {
+        s.Set(e, velocity, Vec2{ 1, 1 })
+      }
+    }
+  }
+  with := position.Mask() | velocity.Mask()
+  dt := float32(1.0 / 60.0)
+  var match []*Archetype
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    for _, a := range s.Match(with, 0, match[:0]) {
+      pos := *a.Column(position).(*Vec2Array)
+      vel := *a.Column(velocity).(*Vec2Array)
+      for i := range pos {
+        pos[i] = pos[i].Add(vel[i].Mul(dt))
+      }
+    }
+  }
+}
+
+func BenchmarkSparseSetAddRemove(b *testing.B) {
+  w := newTestWorld()
+  var position, velocity benchVec2Store
+  position.Init(w)
+  velocity.Init(w)
+  ents := make([]Ent, benchEnts)
+  for i := range ents {
+    ents[i] = w.Ents.Alloc()
+    position.Add(ents[i])
+  }
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    e := ents[n % len(ents)]
+    velocity.Add(e)
+    velocity.Remove(e)
+  }
+}
+
+func BenchmarkArchetypeAddRemove(b *testing.B) {
+  s, ids := newBenchArchetypeStorage(b, 3)
+  ents := append([]Ent(nil), s.Match(ids[0].Mask(), 0, nil)[0].Ents()...)
+  extra := s.RegisterComponent("extra", func() ComponentArray { return &Vec2Array{} })
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    e := ents[n % len(ents)]
+    s.Add(e, extra)
+    s.Remove(e, extra)
+  }
+}
+
+// newBenchArchetypeStorage returns a storage with benchEnts entities which all have
+// ncomp Vec2 components
+func newBenchArchetypeStorage(b *testing.B, ncomp int) (*ArchetypeStorage, []ComponentID) {
+  em := &EntManager{}
+  em.Init()
+  s := &ArchetypeStorage{}
+  s.Init(em)
+  ids := make([]ComponentID, ncomp)
+  var mask ComponentMask
+  for i := range ids {
+    ids[i] = s.RegisterComponent("", func() ComponentArray { return &Vec2Array{} })
+    mask |= ids[i].Mask()
+  }
+  for i := 0; i < benchEnts; i++ {
+    e := em.Alloc()
+    for _, c := range ids {
+      s.Add(e, c)
+    }
+  }
+  if a := s.Match(mask, 0, nil); len(a) != 1 || a[0].Len() != benchEnts {
+    b.Fatal("unexpected archetypes")
+  }
+  return s, ids
+}
+
+// benchVec2Store is a ComponentStore of Vec2 values
+type benchVec2Store struct {
+  ComponentStore
+  data Vec2Array
+}
+
+func (s *benchVec2Store) Init(w *World) { s.ComponentStore.Init(&w.Ents, &s.data) }
@@ -0,0 +1,132 @@
+package main
+
+import "fmt"
+
+// Projection is the kind of projection of a Camera
+type Projection uint8
+const (
+  ProjectionPerspective = Projection(iota)
+  ProjectionOrthographic
+)
+
+func (p Projection) String() string {
+  switch p {
+  case ProjectionPerspective:  return "perspective"
+  case ProjectionOrthographic: return "orthographic"
+  }
+  return "(Projection?)"
+}
+
+func (p Projection) MarshalText() ([]byte, error) {
+  return []byte(p.String()), nil
+}
+
+func (p *Projection) UnmarshalText(text []byte) error {
+  switch string(text) {
+  case "perspective":  *p = ProjectionPerspective
+  case "orthographic": *p = ProjectionOrthographic
+  default:
+    return errorf("invalid projection %q", text)
+  }
+  return nil
+}
+
+// Camera is a component that makes an entity's transform node a point of view.
+// The camera looks down its node's negative Z axis, with positive Y up. Objects are
+// visible between the Near and Far clip planes.
+type Camera struct {
+  Projection Projection `json:"projection"`
+  FOV        float32    `json:"fov"`    // vertical field of view in radians (perspective)
+  Height     float32    `json:"height"` // height of the view in world units (orthographic)
+  Near       float32    `json:"near"`   // distance to the near clip plane
+  Far        float32    `json:"far"`    // distance to the far clip plane
+}
+
+// PerspectiveCamera returns a camera with perspective projection and a vertical field
+// of view of fov radians
+func PerspectiveCamera(fov, near, far float32) Camera {
+  return Camera{ Projection: ProjectionPerspective, FOV: fov, Near: near, Far: far }
+}
+
+// OrthographicCamera returns a camera with orthographic projection that shows height
+// world units vertically
+func OrthographicCamera(height, near, far float32) Camera {
+  return Camera{ Projection: ProjectionOrthographic, Height: height, Near: near, Far: far }
+}
+
+// DefaultCamera is used by the renderer when there's no camera in the world
+var DefaultCamera = PerspectiveCamera(45.0 * (PI / 180.0), 0.1, 100.0)
+
+// ProjectionMatrix returns the projection matrix of c for a view with the given
+// width/height ratio
+func (c *Camera) ProjectionMatrix(aspect float32) Matrix4 {
+  if c.Projection == ProjectionOrthographic {
+    h := c.Height / 2
+    w := h * aspect
+    return Matrix4Orthographic(-w, w, -h, h, c.Near, c.Far)
+  }
+  return Matrix4Perspective(c.FOV, aspect, c.Near, c.Far)
+}
+
+func (c Camera) String() string {
+  if c.Projection == ProjectionOrthographic {
+    return fmt.Sprintf("{orthographic height %g, near %g, far %g}", c.Height, c.Near, c.Far)
+  }
+  return fmt.Sprintf("{perspective fov %g°, near %g, far %g}", c.FOV * (180 / PI), c.Near, c.Far)
+}
+
+// -----------------------------------------------------------------------------
+
+// CameraSystem associates entities with cameras. A camera uses the transform node of
+// its entity (see World.CreateNode) as its position and orientation; an entity without
+// a node is a camera at the origin looking down -Z.
+type CameraSystem struct {
+  ComponentStore
+  world *World
+  data  cameraArray
+}
+
+func (s *CameraSystem) Init(world *World) {
+  s.world = world
+  s.ComponentStore.Init(&world.Ents, &s.data)
+}
+
+// Assoc gives ent a camera. Returns a pointer to the camera which is valid until
+// cameras are added or removed.
+func (s *CameraSystem) Assoc(ent Ent, c Camera) *Camera {
+  i := s.Add(ent)
+  s.data[i] = c
+  return &s.data[i]
+}
+
+// Get returns the camera of ent, or nil if ent has no camera
+func (s *CameraSystem) Get(ent Ent) *Camera {
+  if DEBUG {
+    checkAlive(s.em, ent, 0)
+  }
+  if i := s.Index(ent); i != -1 {
+    return &s.data[i]
+  }
+  return nil
+}
+
+// ViewMatrix returns the view matrix of a camera entity; the inverse of its node's
+// absolute transform
+func (s *CameraSystem) ViewMatrix(ent Ent) Matrix4 {
+  if n := s.world.TransformSystem.Get(ent); n != nil {
+    return n.absolute.Inverse()
+  }
+  return Matrix4Identity
+}
+
+func (s *CameraSystem) DestroyEnt(ent Ent) {
+  s.Remove(ent)
+}
+
+
+type cameraArray []Camera
+
+func (a *cameraArray) Append()               { *a = append(*a, Camera{}) }
+func (a *cameraArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *cameraArray) Truncate(n int)        { *a = (*a)[:n] }
+func (a *cameraArray) Ptr(i int) interface{} { return &(*a)[i] }
@@ -0,0 +1,290 @@
+package main
+
+import "math"
+
+// CameraControlMode is the way a CameraControl responds to input
+type CameraControlMode uint8
+const (
+  // CameraControlOrbit rotates around Target when dragging with the primary button,
+  // pans when dragging with other buttons and zooms with the wheel.
+  CameraControlOrbit = CameraControlMode(iota)
+
+  // CameraControlPan moves Target in the view plane when dragging and zooms with the
+  // wheel. The view direction is fixed by Yaw and Pitch.
+  CameraControlPan
+
+  // CameraControlFly looks around when dragging with the primary button and moves
+  // with the keyboard: W/S or up/down forward and back, A/D or left/right sideways,
+  // E/space up and Q down. Shift moves faster.
+  CameraControlFly
+)
+
+func (m CameraControlMode) String() string {
+  switch m {
+  case CameraControlOrbit: return "orbit"
+  case CameraControlPan:   return "pan"
+  case CameraControlFly:   return "fly"
+  }
+  return "(CameraControlMode?)"
+}
+
+// CameraControl is a component that moves an entity's transform node, usually that
+// of a camera, in response to World.Input. Motion from input is smoothed by Damping.
+//
+// The node's position and rotation are set in the coordinate space of its parent, so
+// controlled nodes are usually at the root of the transform hierarchy.
+type CameraControl struct {
+  Mode     CameraControlMode
+  Target   Vec3    // point orbited or looked at (orbit, pan)
+  Distance float32 // distance from Target (orbit, pan)
+  Yaw      float32 // rotation around Y in radians; 0 looks down -Z
+  Pitch    float32 // rotation around X in radians; positive looks up
+
+  // limits; Distance and Pitch are kept within these when max > min
+  MinDistance, MaxDistance float32
+  MinPitch, MaxPitch       float32
+
+  RotateSpeed float32 // radians per display point of pointer movement
+  PanSpeed    float32 // fraction of Distance per display point of pointer movement
+  ZoomSpeed   float32 // change of Distance (log scale) per display point of wheel movement
+  MoveSpeed   float32 // world units per second (fly)
+  Damping     float32 // seconds for motion to settle after input stops; 0 for none
+
+  // motion from input not yet applied (see Damping)
+  yaw, pitch, zoom float32
+  translation      Vec3
+}
+
+// OrbitCameraControl returns a control that orbits target from distance units away
+func OrbitCameraControl(target Vec3, distance float32) CameraControl {
+  c := defaultCameraControl(CameraControlOrbit)
+  c.Target = target
+  c.Distance = distance
+  return c
+}
+
+// PanCameraControl returns a control that looks down -Z at target from distance units
+// away
+func PanCameraControl(target Vec3, distance float32) CameraControl {
+  c := defaultCameraControl(CameraControlPan)
+  c.Target = target
+  c.Distance = distance
+  return c
+}
+
+// FlyCameraControl returns a control that moves its node from where it is, looking
+// down -Z
+func FlyCameraControl() CameraControl {
+  return defaultCameraControl(CameraControlFly)
+}
+
+func defaultCameraControl(mode CameraControlMode) CameraControl {
+  return CameraControl{
+    Mode:        mode,
+    MinDistance: 0.5,
+    MaxDistance: 100,
+    MinPitch:    -89 * (PI / 180),
+    MaxPitch:    89 * (PI / 180),
+    RotateSpeed: 0.005,
+    PanSpeed:    0.002,
+    ZoomSpeed:   0.002,
+    MoveSpeed:   5,
+    Damping:     0.15,
+  }
+}
+
+// Rotation returns the rotation described by Yaw and Pitch
+func (c *CameraControl) Rotation() Quat {
+  return QuatRotate(c.Yaw, Vec3{0, 1, 0}).Mul(QuatRotate(c.Pitch, Vec3{1, 0, 0}))
+}
+
+// update adds motion from in and applies the share of pending motion that is due
+// after dt seconds to c and node
+func (c *CameraControl) update(in *Input, node *TransformNode, dt float32) {
+  rotation := c.Rotation()
+  switch c.Mode {
+  case CameraControlOrbit:
+    if in.Buttons & ButtonPrimary != 0 {
+      c.yaw -= in.Movement[0] * c.RotateSpeed
+      c.pitch -= in.Movement[1] * c.RotateSpeed
+    } else if in.Buttons != 0 {
+      c.pan(in.Movement, rotation)
+    }
+    c.zoom += in.Wheel[1] * c.ZoomSpeed
+  case CameraControlPan:
+    if in.Buttons != 0 {
+      c.pan(in.Movement, rotation)
+    }
+    c.zoom += in.Wheel[1] * c.ZoomSpeed
+  case CameraControlFly:
+    if in.Buttons & ButtonPrimary != 0 {
+      c.yaw -= in.Movement[0] * c.RotateSpeed
+      c.pitch -= in.Movement[1] * c.RotateSpeed
+    }
+    if dir := flyDirection(in); dir != (Vec3{}) {
+      speed := c.MoveSpeed
+      if in.KeyDown(KeyShift) {
+        speed *= 4
+      }
+      c.translation = c.translation.Add(rotation.Rotate(dir).Mul(speed * dt))
+    }
+  }
+
+  // apply the share of pending motion that is due
+  k := float32(1)
+  if c.Damping > 0 {
+    k = 1 - float32(math.Exp(float64(-4 * dt / c.Damping)))
+  }
+  yaw, pitch, zoom, translation := c.yaw * k, c.pitch * k, c.zoom * k, c.translation.Mul(k)
+  c.yaw -= yaw
+  c.pitch -= pitch
+  c.zoom -= zoom
+  c.translation = c.translation.Sub(translation)
+
+  c.Yaw += yaw
+  c.Pitch = clampLimits(c.Pitch + pitch, c.MinPitch, c.MaxPitch)
+  if c.Pitch == c.MinPitch || c.Pitch == c.MaxPitch {
+    c.pitch = 0 // don't keep pushing against the limit
+  }
+  rotation = c.Rotation()
+
+  var position Vec3
+  if c.Mode == CameraControlFly {
+    position = node.Position().Add(translation)
+  } else {
+    c.Target = c.Target.Add(translation)
+    c.Distance = clampLimits(
+      c.Distance * float32(math.Exp(float64(zoom))), c.MinDistance, c.MaxDistance)
+    position = c.Target.Add(rotation.Rotate(Vec3{0, 0, c.Distance}))
+  }
+  if position != node.Position() {
+    node.SetPosition(position)
+  }
+  if rotation.Normalize() != node.Rotation() {
+    node.SetRotation(rotation)
+  }
+}
+
+// pan adds movement of Target in the view plane for pointer movement, so that the
+// scene follows the pointer
+func (c *CameraControl) pan(movement Vec2, rotation Quat) {
+  scale := c.Distance * c.PanSpeed
+  right, up := rotation.Rotate(Vec3{1, 0, 0}), rotation.Rotate(Vec3{0, 1, 0})
+  c.translation = c.translation.
+    Add(right.Mul(-movement[0] * scale)).
+    Add(up.Mul(movement[1] * scale))
+}
+
+// flyDirection returns the direction of movement in camera space for pressed keys
+func flyDirection(in *Input) Vec3 {
+  var dir Vec3
+  if in.KeyDown(KeyW) || in.KeyDown(KeyUp)    { dir[2] -= 1 }
+  if in.KeyDown(KeyS) || in.KeyDown(KeyDown)  { dir[2] += 1 }
+  if in.KeyDown(KeyA) || in.KeyDown(KeyLeft)  { dir[0] -= 1 }
+  if in.KeyDown(KeyD) || in.KeyDown(KeyRight) { dir[0] += 1 }
+  if in.KeyDown(KeyE) || in.KeyDown(KeySpace) { dir[1] += 1 }
+  if in.KeyDown(KeyQ)                         { dir[1] -= 1 }
+  if l := dir.Len(); l > 0 {
+    dir = dir.Mul(1 / l)
+  }
+  return dir
+}
+
+// clampLimits returns v limited to min...max, or v if max <= min (no limits)
+func clampLimits(v, min, max float32) float32 {
+  if max > min {
+    if v < min {
+      return min
+    }
+    if v > max {
+      return max
+    }
+  }
+  return v
+}
+
+// -----------------------------------------------------------------------------
+
+// CameraControlSystem moves entities with a CameraControl in response to World.Input.
+// Camera controls are interactive state and are not saved with scenes.
+//
+// Example:
+//
+//   var controls CameraControlSystem
+//   controls.AddToWorld(w)
+//   controls.Assoc(cameraEnt, OrbitCameraControl(Vec3{}, 5))
+//
+type CameraControlSystem struct {
+  ComponentStore
+  world          *World
+  data           cameraControlArray
+  lastUpdateTime float64
+}
+
+// maxCameraControlStep limits the time step of CameraControlSystem.Update, so that a
+// long pause between updates (e.g. a hidden browser tab) doesn't make flying cameras
+// jump.
+const maxCameraControlStep = 0.1
+
+func (s *CameraControlSystem) Init(world *World) {
+  s.world = world
+  s.ComponentStore.Init(&world.Ents, &s.data)
+}
+
+// AddToWorld initializes s for w and schedules it to run during PhaseInput
+func (s *CameraControlSystem) AddToWorld(w *World) {
+  s.Init(w)
+  w.AddSystem(s)
+  w.Schedule(PhaseInput, "camera-control", s)
+}
+
+// Assoc gives ent a camera control. ent should have a transform node.
+// Returns a pointer to the control which is valid until controls are added or removed.
+func (s *CameraControlSystem) Assoc(ent Ent, c CameraControl) *CameraControl {
+  i := s.Add(ent)
+  s.data[i] = c
+  return &s.data[i]
+}
+
+// Get returns the camera control of ent, or nil if ent has none
+func (s *CameraControlSystem) Get(ent Ent) *CameraControl {
+  if DEBUG {
+    checkAlive(s.em, ent, 0)
+  }
+  if i := s.Index(ent); i != -1 {
+    return &s.data[i]
+  }
+  return nil
+}
+
+func (s *CameraControlSystem) DestroyEnt(ent Ent) {
+  s.Remove(ent)
+}
+
+// Update applies World.Input to all controls. Called by World.Update during PhaseInput.
+func (s *CameraControlSystem) Update(time float64) {
+  dt := float32(time - s.lastUpdateTime)
+  s.lastUpdateTime = time
+  if dt < 0 {
+    dt = 0
+  } else if dt > maxCameraControlStep {
+    dt = maxCameraControlStep
+  }
+  for i := range s.data {
+    e := s.EntAt(i)
+    if !s.world.Ents.IsAlive(e) {
+      continue // freed without World.Destroy
+    }
+    if node := s.world.TransformSystem.Get(e); node != nil {
+      s.data[i].update(&s.world.Input, node, dt)
+    }
+  }
+}
+
+
+type cameraControlArray []CameraControl
+
+func (a *cameraControlArray) Append()               { *a = append(*a, CameraControl{}) }
+func (a *cameraControlArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *cameraControlArray) Truncate(n int)        { *a = (*a)[:n] }
+func (a *cameraControlArray) Ptr(i int) interface{} { return &(*a)[i] }
@@ -0,0 +1,80 @@
+package main
+
+// Clock drives simulation at a fixed rate, independent of the frame rate.
+//
+// Each frame, Advance adds the real time passed since the last frame (multiplied by
+// Scale) to an accumulator and returns the number of whole FixedStep ticks to run.
+// The remainder is kept for the next frame and exposed as Alpha, the fraction of a
+// tick between the previous tick and the current tick, which renderers can use to
+// interpolate between the previous and current simulation state.
+//
+type Clock struct {
+  FixedStep    float64 // duration of a simulation tick, in seconds
+  Scale        float64 // time scale; 1 = real time, 0.5 = half speed
+  MaxFrameTime float64 // frame time is clamped to this to avoid a "spiral of death"
+  Paused       bool    // when true, time does not advance (see Step)
+
+  Time  float64 // simulation time in seconds; Ticks * FixedStep
+  Ticks uint64  // number of ticks run
+  Alpha float64 // interpolation factor between the previous and current tick [0-1)
+
+  realTime    float64 // time of last call to Advance
+  started     bool    // true after the first call to Advance
+  accumulator float64 // scaled time not yet consumed by ticks
+  steps       int     // ticks requested by Step while paused
+}
+
+func (c *Clock) Init() {
+  *c = Clock{
+    FixedStep:    1.0 / 60.0,
+    Scale:        1.0,
+    MaxFrameTime: 0.25,
+  }
+}
+
+// Advance moves the clock to realTime (in seconds) and returns the number of ticks
+// to run. Call Tick once for every tick.
+func (c *Clock) Advance(realTime float64) int {
+  dt := realTime - c.realTime
+  c.realTime = realTime
+  if !c.started {
+    c.started = true
+    dt = 0
+  }
+  if dt > c.MaxFrameTime {
+    dt = c.MaxFrameTime
+  }
+  n := 0
+  if c.Paused {
+    n = c.steps
+    c.steps = 0
+  } else if dt > 0 {
+    c.accumulator += dt * c.Scale
+    n = int(c.accumulator / c.FixedStep)
+    c.accumulator -= float64(n) * c.FixedStep
+  }
+  c.Alpha = c.accumulator / c.FixedStep
+  return n
+}
+
+// Tick advances simulation time by one FixedStep and returns the new time
+func (c *Clock) Tick() float64 {
+  c.Ticks++
+  c.Time = float64(c.Ticks) * c.FixedStep
+  return c.Time
+}
+
+// Step requests a single tick to be run by the next Advance while the clock is paused
+func (c *Clock) Step() {
+  c.steps++
+}
+
+// RenderTime returns the simulation time interpolated between the previous and the
+// current tick, matching state interpolated with Alpha. It's never negative, as
+// the first frame is rendered before the first tick.
+func (c *Clock) RenderTime() float64 {
+  if t := c.Time - (1 - c.Alpha) * c.FixedStep; t > 0 {
+    return t
+  }
+  return 0
+}
@@ -0,0 +1,144 @@
+package main
+
+// CommandBuffer records structural changes to a World to be applied later.
+//
+// Adding or removing entities, components and transform nodes while iterating over
+// components is unsafe since it moves component data around in memory, invalidating
+// pointers and dense indices. Systems should instead record such changes in
+// World.Commands, which World.Update applies at the end of each phase.
+//
+type CommandBuffer struct {
+  world    *World
+  commands []command
+}
+
+type commandKind uint8
+const (
+  cmdDestroy    = commandKind(iota) // World.Destroy(ent)
+  cmdAdd                            // store.Set(ent, value)
+  cmdRemove                         // store.Remove(ent)
+  cmdCreateNode                     // TransformSystem.CreateNode(ent, local)
+  cmdSetParent                      // make ent a child of arg
+  cmdReparent                       // TransformSystem.Reparent(ent, arg)
+  cmdFunc                           // fn(world)
+)
+
+type command struct {
+  kind  commandKind
+  ent   Ent
+  arg   Ent
+  store *ComponentStore
+  value interface{}
+  local Matrix4
+  fn    func(*World)
+}
+
+func (b *CommandBuffer) Init(world *World) {
+  b.world = world
+  b.commands = b.commands[:0]
+}
+
+// Len returns the number of commands waiting to be applied
+func (b *CommandBuffer) Len() int {
+  return len(b.commands)
+}
+
+// Spawn allocates a new entity. The entity is allocated immediately so that it can be
+// referenced by other commands, but it has no components until the buffer is applied.
+func (b *CommandBuffer) Spawn() Ent {
+  return b.world.Ents.Alloc()
+}
+
+// Destroy records a call to World.Destroy(e)
+func (b *CommandBuffer) Destroy(e Ent) {
+  b.commands = append(b.commands, command{ kind: cmdDestroy, ent: e })
+}
+
+// Add records adding a component with value to e. If value is nil, a zero value is added.
+func (b *CommandBuffer) Add(store *ComponentStore, e Ent, value interface{}) {
+  b.commands = append(b.commands, command{ kind: cmdAdd, ent: e, store: store, value: value })
+}
+
+// Remove records removing e's component from store
+func (b *CommandBuffer) Remove(store *ComponentStore, e Ent) {
+  b.commands = append(b.commands, command{ kind: cmdRemove, ent: e, store: store })
+}
+
+// CreateNode records creating a transform node for e
+func (b *CommandBuffer) CreateNode(e Ent, local Matrix4) {
+  b.commands = append(b.commands, command{ kind: cmdCreateNode, ent: e, local: local })
+}
+
+// SetParent records moving the transform node of e to become the last child of parent,
+// keeping its local transform. If parent is NilEnt, e's node becomes a root node.
+func (b *CommandBuffer) SetParent(e, parent Ent) {
+  b.commands = append(b.commands, command{ kind: cmdSetParent, ent: e, arg: parent })
+}
+
+// Reparent records a call to TransformSystem.Reparent(e, parent), which moves e's node
+// while keeping its absolute transform.
+func (b *CommandBuffer) Reparent(e, parent Ent) {
+  b.commands = append(b.commands, command{ kind: cmdReparent, ent: e, arg: parent })
+}
+
+// Do records a call to fn, for changes not covered by the other commands
+func (b *CommandBuffer) Do(fn func(*World)) {
+  b.commands = append(b.commands, command{ kind: cmdFunc, fn: fn })
+}
+
+// Apply applies all recorded commands in the order they were recorded.
+// Commands recorded while applying are applied as well.
+func (b *CommandBuffer) Apply() {
+  for len(b.commands) > 0 {
+    commands := b.commands
+    b.commands = nil
+    for i := range commands {
+      b.apply(&commands[i])
+      commands[i] = command{} // release references
+    }
+    if b.commands == nil {
+      b.commands = commands[:0] // reuse memory
+    }
+  }
+}
+
+func (b *CommandBuffer) apply(c *command) {
+  w := b.world
+  switch c.kind {
+  case cmdDestroy:
+    w.Destroy(c.ent)
+  case cmdAdd:
+    if !w.Ents.IsAlive(c.ent) {
+      return
+    }
+    if c.value == nil {
+      c.store.Add(c.ent)
+    } else {
+      c.store.Set(c.ent, c.value)
+    }
+  case cmdRemove:
+    c.store.Remove(c.ent)
+  case cmdCreateNode:
+    if w.Ents.IsAlive(c.ent) {
+      w.TransformSystem.CreateNode(c.ent, c.local)
+    }
+  case cmdSetParent, cmdReparent:
+    ts := &w.TransformSystem
+    if !w.Ents.IsAlive(c.ent) || (c.arg != NilEnt && !w.Ents.IsAlive(c.arg)) {
+      return
+    }
+    n := ts.Get(c.ent)
+    if n == nil || (c.arg != NilEnt && ts.Get(c.arg) == nil) {
+      return
+    }
+    if c.kind == cmdReparent {
+      ts.Reparent(c.ent, c.arg)
+    } else if c.arg != NilEnt {
+      ts.AppendChild(c.arg, c.ent)
+    } else {
+      ts.RemoveChild(n.parent, c.ent)
+    }
+  case cmdFunc:
+    c.fn(w)
+  }
+}
@@ -0,0 +1,274 @@
+package main
+
+import "reflect"
+
+// ComponentStore maps entities to component data using a sparse set.
+//
+// Data is kept densely packed in a ComponentArray, with ents[i] being the owner of the
+// i:th value. The sparse array is indexed by EntManager.Index and holds the dense index + 1
+// for each entity that has a component (0 means "not present".) This gives us O(1)
+// Add, Get, Has and Remove while keeping data contiguous for fast iteration.
+//
+// Remove moves the last value into the hole left by the removed value ("swap remove")
+// so the dense order is insertion order except for values moved by Remove. Iterating
+// from the end towards the beginning is safe even when removing the current entity.
+//
+// Changes are tracked with a per-store version number which is incremented by Add, Set
+// and MarkChanged. The version of the most recent change of each value is recorded,
+// which allows Query.Changed to find values that changed since the query last ran.
+// Code that modifies values through pointers must call MarkChanged for this to work.
+// Functions registered with OnAdd, OnRemove and OnChange are called synchronously.
+//
+// Typical use by a system that owns some component data T:
+//
+//   type TArray []T
+//   func (a *TArray) Append()               { *a = append(*a, T{}) }
+//   func (a *TArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+//   func (a *TArray) Truncate(n int)        { *a = (*a)[:n] }
+//   func (a *TArray) Ptr(i int) interface{} { return &(*a)[i] }
+//
+//   type TSystem struct {
+//     ComponentStore
+//     data TArray
+//   }
+//   func (s *TSystem) Init(world *World) { s.ComponentStore.Init(&world.Ents, &s.data) }
+//
+type ComponentStore struct {
+  ents   []Ent          // dense; ents[i] owns data value i
+  sparse []int32        // EntManager.Index(e) => dense index + 1
+  data   ComponentArray // dense component values
+  em     *EntManager    // layout of entities; used to check that they are alive
+
+  version  uint32   // incremented for every change
+  changed  []uint32 // dense; version of the most recent change of each value
+  onAdd    []ComponentObserver
+  onRemove []ComponentObserver
+  onChange []ComponentObserver
+}
+
+// ComponentObserver is a function called when a component of e was added, removed or
+// changed. For removal, it's called just before the value is removed.
+// Observers must not add or remove values of the store they observe; use a
+// CommandBuffer for that.
+type ComponentObserver func(e Ent)
+
+// ComponentArray is the dense storage of a ComponentStore.
+// It's usually implemented by a slice type with pointer receivers.
+type ComponentArray interface {
+  Append()               // append one zero value
+  Move(dst, src int)     // copy value at src to dst
+  Truncate(n int)        // shrink to n values
+  Ptr(i int) interface{} // returns a pointer to the value at i, e.g. *MovementData
+}
+
+// Init initializes the store for entities of em, which must have been initialized.
+// em gives the layout of entities, and Add, Get, Has and Index reject entities that
+// are not alive according to em.
+func (c *ComponentStore) Init(em *EntManager, data ComponentArray) {
+  if em == nil {
+    panicf("ComponentStore.Init: nil EntManager")
+  }
+  c.em = em
+  c.data = data
+  c.ents = c.ents[:0]
+  c.sparse = c.sparse[:0]
+  c.changed = c.changed[:0]
+  c.onAdd = c.onAdd[:0]
+  c.onRemove = c.onRemove[:0]
+  c.onChange = c.onChange[:0]
+  data.Truncate(0)
+}
+
+// OnAdd registers fn to be called after a component is added to an entity
+func (c *ComponentStore) OnAdd(fn ComponentObserver) {
+  c.onAdd = append(c.onAdd, fn)
+}
+
+// OnRemove registers fn to be called before a component is removed from an entity
+func (c *ComponentStore) OnRemove(fn ComponentObserver) {
+  c.onRemove = append(c.onRemove, fn)
+}
+
+// OnChange registers fn to be called after a component value was changed by Set or
+// MarkChanged
+func (c *ComponentStore) OnChange(fn ComponentObserver) {
+  c.onChange = append(c.onChange, fn)
+}
+
+// Version returns the store's change version, which is incremented for every change
+func (c *ComponentStore) Version() uint32 {
+  return c.version
+}
+
+// ChangedSince returns true if e's component was added or changed after version
+func (c *ComponentStore) ChangedSince(e Ent, version uint32) bool {
+  i := c.Index(e)
+  return i != -1 && c.changed[i] > version
+}
+
+// MarkChanged records that e's component value has changed and notifies observers
+func (c *ComponentStore) MarkChanged(e Ent) {
+  if i := c.Index(e); i != -1 {
+    c.markChangedAt(i)
+  }
+}
+
+func (c *ComponentStore) markChangedAt(i int) {
+  c.version++
+  c.changed[i] = c.version
+  for _, fn := range c.onChange {
+    fn(c.ents[i])
+  }
+}
+
+// Len returns the number of entities with a component
+func (c *ComponentStore) Len() int {
+  return len(c.ents)
+}
+
+// Ents returns the entities that has a component, in dense order.
+// The returned slice is only valid until the next call to Add or Remove.
+func (c *ComponentStore) Ents() []Ent {
+  return c.ents
+}
+
+// EntAt returns the entity owning the value at dense index i
+func (c *ComponentStore) EntAt(i int) Ent {
+  return c.ents[i]
+}
+
+// Index returns the dense index of e's component value, or -1 if e has no component.
+func (c *ComponentStore) Index(e Ent) int {
+  i := c.storedIndex(e)
+  if i == -1 || !c.em.IsAlive(e) {
+    return -1
+  }
+  return i
+}
+
+// storedIndex is like Index but also finds values of entities that are no longer alive
+func (c *ComponentStore) storedIndex(e Ent) int {
+  x := c.em.Index(e)
+  if int(x) >= len(c.sparse) {
+    return -1
+  }
+  i := int(c.sparse[x]) - 1
+  // Note: comparing the full Ent (and not just the index) makes sure that an entity
+  // reusing the index slot of an older entity is not mistaken for the older one.
+  if i < 0 || c.ents[i] != e {
+    return -1
+  }
+  return i
+}
+
+// Has returns true if e has a component in this store
+func (c *ComponentStore) Has(e Ent) bool {
+  return c.Index(e) != -1
+}
+
+// Add adds a zero-value component for e and returns its dense index.
+// If e already has a component, the index of the existing value is returned.
+func (c *ComponentStore) Add(e Ent) int {
+  if i := c.Index(e); i != -1 {
+    return i
+  }
+  if !c.em.IsAlive(e) {
+    panicf("ComponentStore.Add: entity %#v is not alive", e)
+  }
+  x := int(c.em.Index(e))
+  if x >= len(c.sparse) {
+    if x < cap(c.sparse) {
+      c.sparse = c.sparse[:x+1]
+    } else {
+      sparse := make([]int32, x+1, max(x+1, cap(c.sparse)*2))
+      copy(sparse, c.sparse)
+      c.sparse = sparse
+    }
+  } else if c.sparse[x] != 0 {
+    // the slot is held by a dead entity with an older generation
+    c.removeAt(int(c.sparse[x]) - 1)
+  }
+  i := len(c.ents)
+  c.ents = append(c.ents, e)
+  c.data.Append()
+  c.sparse[x] = int32(i + 1)
+  c.version++
+  c.changed = append(c.changed, c.version)
+  for _, fn := range c.onAdd {
+    fn(e)
+  }
+  return i
+}
+
+// Get returns a pointer to e's component value, or nil if e has no component.
+// The pointer is only valid until the next call to Add or Remove.
+func (c *ComponentStore) Get(e Ent) interface{} {
+  if DEBUG {
+    checkAlive(c.em, e, 0)
+  }
+  i := c.Index(e)
+  if i == -1 {
+    return nil
+  }
+  return c.data.Ptr(i)
+}
+
+// Type returns the type of component values, e.g. MovementData.
+// Requires the ComponentArray to be a slice type (or a pointer to one.)
+func (c *ComponentStore) Type() reflect.Type {
+  t := reflect.TypeOf(c.data)
+  for t.Kind() == reflect.Ptr {
+    t = t.Elem()
+  }
+  if t.Kind() != reflect.Slice {
+    panicf("ComponentStore.Type: %s is not a slice type", reflect.TypeOf(c.data))
+  }
+  return t.Elem()
+}
+
+// Set adds or replaces e's component with value, which must be of the component's type
+// (e.g. MovementData for a store of MovementData.) Returns the dense index of the value.
+func (c *ComponentStore) Set(e Ent, value interface{}) int {
+  if DEBUG {
+    checkAlive(c.em, e, 0)
+  }
+  i := c.Add(e)
+  dst := reflect.ValueOf(c.data.Ptr(i)).Elem()
+  src := reflect.ValueOf(value)
+  if src.Type() != dst.Type() {
+    panicf("ComponentStore.Set: value of type %s is not a %s", src.Type(), dst.Type())
+  }
+  dst.Set(src)
+  c.markChangedAt(i)
+  return i
+}
+
+// Remove removes e's component. Returns false if e did not have a component.
+func (c *ComponentStore) Remove(e Ent) bool {
+  // Note: we intentionally don't check em.IsAlive here so that components can be
+  // removed from entities that have already been freed.
+  i := c.storedIndex(e)
+  if i == -1 {
+    return false
+  }
+  c.removeAt(i)
+  return true
+}
+
+func (c *ComponentStore) removeAt(i int) {
+  for _, fn := range c.onRemove {
+    fn(c.ents[i])
+  }
+  last := len(c.ents) - 1
+  c.sparse[c.em.Index(c.ents[i])] = 0
+  if i != last {
+    e := c.ents[last]
+    c.ents[i] = e
+    c.data.Move(i, last)
+    c.changed[i] = c.changed[last]
+    c.sparse[c.em.Index(e)] = int32(i + 1)
+  }
+  c.ents = c.ents[:last]
+  c.changed = c.changed[:last]
+  c.data.Truncate(last)
+}
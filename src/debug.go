@@ -0,0 +1,23 @@
+// +build debug
+
+package main
+
+import "runtime"
+
+// DEBUG is true when built with -tags debug, which enables extra runtime checks
+const DEBUG = true
+
+// checkAlive panics if e is not alive in em, reporting the call site.
+// skip is the number of stack frames between the call site and the function calling
+// checkAlive, e.g. 0 to report the caller of ComponentStore.Get.
+func checkAlive(em *EntManager, e Ent, skip int) {
+  if e == NilEnt || em.IsAlive(e) {
+    return
+  }
+  _, file, line, ok := runtime.Caller(skip + 2)
+  if !ok {
+    file, line = "?", 0
+  }
+  panicf("use of dead entity %#v (index %d, generation %d) at %s:%d",
+    e, em.Index(e), em.Generation(e), file, line)
+}
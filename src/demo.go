@@ -0,0 +1,58 @@
+package main
+
+// createRenderDemo adds drawable entities to w, which must have been added to r
+// with Renderer.AddToWorld
+func createRenderDemo(w *World, r *Renderer) {
+  cubeProgram, err := NewGLProgramSource(r.gl, cubeVertexShaderSrc, cubeFragmentShaderSrc)
+  if err != nil {
+    panic(err)
+  }
+  cubeMaterial := NewMaterial(cubeProgram)
+  cubeMaterial.Name = "cube"
+  cubeMaterial.State.Cull = CullBack
+  cube, err := NewGLCube(cubeMaterial)
+  if err != nil {
+    panic(err)
+  }
+
+  // a camera looking down -Z from 5.5 units away from the origin, which can be orbited
+  // around the origin with the pointer and wheel
+  cam := w.Ents.Alloc()
+  w.Names.SetName(cam, "camera")
+  camtm := Matrix4Identity
+  camtm.Translate(0, 0, 5.5)
+  w.CreateNode(cam, camtm)
+  w.Cameras.Assoc(cam, PerspectiveCamera(45.0 * (PI / 180.0), 0.1, 100.0))
+  r.Camera = cam
+  controls := &CameraControlSystem{}
+  controls.AddToWorld(w)
+  controls.Assoc(cam, OrbitCameraControl(Vec3{}, 5.5))
+
+  // a cube in front of the camera, animated by cubeAnimator
+  e := w.Ents.Alloc()
+  w.Names.SetName(e, "cube")
+  w.CreateNode(e, Matrix4Identity)
+  r.Drawables.Assoc(e, cube)
+
+  w.Schedule(PhaseSimulation, "cube-animator", &cubeAnimator{ node: e, world: w })
+}
+
+
+// cubeAnimator moves, rotates and scales a transform node over time
+type cubeAnimator struct {
+  world *World
+  node  Ent
+}
+
+func (a *cubeAnimator) Update(time float64) {
+  n := a.world.Get(a.node)
+  if n == nil {
+    return
+  }
+  t := float32(time)
+  tm := Matrix4Identity
+  tm.Translate(sin32(t*0.5) * 1.0, cos32(t) * 1.5, 0.0)
+  tm.Rotate(sin32(t * 0.8), cos32(t * 0.5), t * 2)
+  tm.Scale(0.2 + abs32(sin32(t)), 0.2 + abs32(cos32(t)), 0.5)
+  n.SetLocal(&tm)
+}
@@ -0,0 +1,110 @@
+package main
+
+import "sync/atomic"
+
+// GraphicsDevice is the subset of GL that the renderer and drawables use.
+// Backends:
+//
+//   GLContext        WebGL in a browser (js,wasm only)
+//   RecordingDevice  headless; records commands and resources, e.g. for tests
+//   SoftwareDevice   headless; rasterizes into an image on the CPU
+//
+// Method names and semantics mirror the WebGL functions of the same names.
+// Implementations may defer commands until flush is called, which the renderer does
+// at the end of each frame.
+type GraphicsDevice interface {
+  // drawingBufferSize returns the size in pixels of the buffer drawn to
+  drawingBufferSize() (width, height uint32)
+
+  // setCanvasSize sets the size of the drawing surface in display points
+  setCanvasSize(width, height uint32, pixelRatio float32)
+
+  viewport(x, y int32, width, height uint32)
+  clear(mask uint32)
+  clearColor(r, g, b, a float32)
+  clearDepth(d float32)
+  enable(cap uint32)
+  disable(cap uint32)
+  depthFunc(funcid uint32)
+  depthMask(write bool)
+  blendFunc(sfactor, dfactor uint32)
+  cullFace(mode uint32)
+
+  createBuffer() GLBuffer
+  deleteBuffer(b GLBuffer)
+  bindBuffer(target uint32, buffer GLBuffer)
+  bufferDataF32(target uint32, data []float32, usage uint32)
+  bufferDataU16(target uint32, data []uint16, usage uint32)
+
+  createTexture() GLTexture
+  deleteTexture(t GLTexture)
+  activeTexture(texture uint32) // e.g. GL_TEXTURE0 + unit
+  bindTexture(target uint32, t GLTexture)
+  texParameteri(target, pname uint32, param int32)
+
+  // texImage2D sets the image of the texture bound to target to width x height RGBA
+  // pixels with 8 bits per channel. The first row of pixels is at t=0.
+  texImage2D(target, width, height uint32, pixels []uint8)
+
+  // createProgram compiles and links a program from GLSL source
+  createProgram(vertexSource, fragmentSource string) (*GLProgram, error)
+  uniformLocation(p *GLProgram, name string) (GLUniform, error)
+  attribLocation(p *GLProgram, name string) (uint32, error)
+  useProgram(p *GLProgram) bool // returns false if p was already in use
+
+  uniformMatrix4fv(location GLUniform, transpose bool, value [16]float32)
+  uniformf(location GLUniform, value ...float32)
+  uniformi(location GLUniform, value ...int32)
+
+  vertexAttribPointer(index, size uint32, typ GLenum, normalized bool, stride, offset uint32)
+  enableVertexAttribArray(index uint32)
+  disableVertexAttribArray(index uint32)
+
+  drawArrays(mode, first, count uint32)
+  drawElements(mode, count, kind, offset uint32)
+
+  // flush executes deferred commands
+  flush()
+}
+
+// GLUniform is a uniform location. The WebGL backend uses its id in the host's GL
+// object table (see GLCommandBuffer.)
+type GLUniform uint32
+
+// GLBuffer is a buffer object. The id allows the state cache to compare buffers
+// and is the buffer's handle in the host's GL object table.
+type GLBuffer struct {
+  id uintptr // 0 for the "null" buffer
+}
+
+// GLTexture is a texture object. Like GLBuffer, the id is the texture's handle.
+type GLTexture struct {
+  id uintptr // 0 for the "null" texture
+}
+
+// GLProgram is a linked shader program, created with NewGLProgramSource
+type GLProgram struct {
+  id  uintptr
+  dev GraphicsDevice
+}
+
+func NewGLProgramSource(dev GraphicsDevice, vSource, fSource string) (*GLProgram, error) {
+  return dev.createProgram(vSource, fSource)
+}
+
+func (p *GLProgram) getUniformLocation(name string) (GLUniform, error) {
+  return p.dev.uniformLocation(p, name)
+}
+
+func (p *GLProgram) getAttribLocation(name string) (uint32, error) {
+  return p.dev.attribLocation(p, name)
+}
+
+
+// --------------------------------------------------------------------------------------
+
+var glNextID uintptr = 0
+
+func glGenID() uintptr {
+  return atomic.AddUintptr(&glNextID, 1)
+}
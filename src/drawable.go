@@ -0,0 +1,58 @@
+package main
+
+// Drawable is implemented by things that a Renderer can draw, like GLCube and GLPlane.
+// A Drawable holds GPU resources but no transform and can be shared by many entities.
+type Drawable interface {
+  // Material returns the material that the drawable is drawn with. The renderer sorts
+  // drawables by material to minimize state changes.
+  Material() *Material
+
+  // Draw binds the drawable's geometry and draws it. The renderer has applied the
+  // drawable's material and set uModelViewMatrix to modelView; the absolute transform
+  // of the entity's node as seen from the renderer's camera.
+  Draw(r *Renderer, modelView *Matrix4)
+}
+
+// DrawableSystem associates entities with Drawables.
+// Entities that have both a drawable and a transform node are drawn by the Renderer,
+// using the absolute transform of the node as the model matrix.
+type DrawableSystem struct {
+  ComponentStore
+  data drawableArray
+}
+
+func (s *DrawableSystem) Init(world *World) {
+  s.ComponentStore.Init(&world.Ents, &s.data)
+}
+
+func (s *DrawableSystem) Assoc(ent Ent, d Drawable) {
+  s.data[s.Add(ent)] = d
+}
+
+// Get returns the drawable of ent, or nil if ent has no drawable
+func (s *DrawableSystem) Get(ent Ent) Drawable {
+  if DEBUG {
+    checkAlive(s.em, ent, 0)
+  }
+  if i := s.Index(ent); i != -1 {
+    return s.data[i]
+  }
+  return nil
+}
+
+// At returns the drawable at dense index i, e.g. from Query.Index
+func (s *DrawableSystem) At(i int) Drawable {
+  return s.data[i]
+}
+
+func (s *DrawableSystem) DestroyEnt(ent Ent) {
+  s.Remove(ent)
+}
+
+
+type drawableArray []Drawable
+
+func (a *drawableArray) Append()               { *a = append(*a, nil) }
+func (a *drawableArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *drawableArray) Truncate(n int)        { *a = (*a)[:n] }
+func (a *drawableArray) Ptr(i int) interface{} { return &(*a)[i] }
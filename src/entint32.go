@@ -0,0 +1,10 @@
+// +build !ent64
+
+package main
+
+// entint is the integer type of Ent. Build with -tags ent64 for 64-bit entities.
+type entint = uint32
+
+// DefaultEntityGenerationBits is the default EntManager.GenerationBits: 24 bits for
+// the index and 8 bits for the generation
+const DefaultEntityGenerationBits uint = 8
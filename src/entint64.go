@@ -0,0 +1,10 @@
+// +build ent64
+
+package main
+
+// entint is the integer type of Ent. Build without -tags ent64 for 32-bit entities.
+type entint = uint64
+
+// DefaultEntityGenerationBits is the default EntManager.GenerationBits: 32 bits for
+// the index and 32 bits for the generation
+const DefaultEntityGenerationBits uint = 32
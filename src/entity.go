@@ -2,7 +2,6 @@ package main
 
 import "fmt"
 
-type entint = uint32
 const entintSize uint = 32 << (^entint(0) >> 63)
 const entintMax entint = ^entint(0)
 
@@ -11,30 +10,31 @@ const entintMax entint = ^entint(0)
 // at the same index slot. As we create and destroy entities we will at some point
 // have to reuse an index in the array. By changing the generation value when that
 // happens we ensure that we still get a unique ID.
-const (
-  EntityGenerationBits = entint(8)
-  EntityGenerationMask = entint((1 << EntityGenerationBits) - 1)
-  EntityIndexBits = entint(entintSize) - EntityGenerationBits
-  EntityIndexMask = entint((1 << EntityIndexBits) - 1)
-)
-
-// We've split up our 32 bits into 24 bits for the index and 8 bits for the
-// generation. This means that we support a maximum of 16 million simultaneous
-// entities (2^24). It also means that we can only distinguish between 256 different
-// entities created at the same index slot. If more than 256 entities are created
-// at the same index slot, the generation value will wrap around and our new entity
-// will get the same ID as an old entity.
+//
+// The split is set per EntManager with GenerationBits and is fixed when the manager
+// is initialized. Since an Ent alone doesn't know its layout, the index and generation
+// of an Ent are read with EntManager.Index and EntManager.Generation.
+//
+// With the default 32-bit layout we split up our 32 bits into 24 bits for the index
+// and 8 bits for the generation. This means that we support a maximum of 16 million
+// simultaneous entities (2^24). It also means that we can only distinguish between
+// 256 different entities created at the same index slot. If more than 256 entities
+// are created at the same index slot, the generation value will wrap around and our
+// new entity will get the same ID as an old entity.
 //
 // To prevent that from happening too often we need to make sure that we don't
 // reuse the same index slot too often. There are various possible ways of doing
 // that. Our solution is to put recycled indices in a queue and only reuse values
-// from that queue when it contains at least MinimumFreeIndices = 1024 items.
+// from that queue when it contains at least EntManager.MinFreeIndices items
+// (MinimumFreeIndices = 1024 by default.)
 // Since we have 256 generations, an ID will never reappear until its index has run
 // 256 laps through the queue. So this means that you must create and destroy at
 // least 256 * 1024 entities until an ID can reappear. This seems reasonably safe,
 // but if you want you can play with the numbers to get different margins. For
 // example, if you don't need 16 M entities, you can steal some bits from index and
-// give to generation.
+// give to generation (EntManager.GenerationBits), or raise MinFreeIndices. For
+// long-running sessions, building with -tags ent64 gives 64-bit Ents with 2^32
+// generations per index by default.
 //
 const MinimumFreeIndices = 1024
 
@@ -43,100 +43,170 @@ const MinimumFreeIndices = 1024
 type Ent entint
 const NilEnt = Ent(0)
 
-func (e Ent) Index() entint {
-  return entint(e) & EntityIndexMask
-}
-
-func (e Ent) Generation() uint8 {
-  return uint8((entint(e) >> EntityIndexBits) & EntityGenerationMask)
-}
-
 func (e Ent) String() string {
   return fmt.Sprintf("Ent#%X", entint(e))
 }
 
 func (e Ent) GoString() string {
-  return fmt.Sprintf("Ent{%d,%d}", e.Index(), e.Generation())
-}
-
-func createEnt(index entint, generation uint8) Ent {
-  return Ent(entint(generation) << EntityIndexBits | index)
+  return fmt.Sprintf("Ent(%#x)", entint(e))
 }
 
 
 
 type EntManager struct {
-  generation  []byte
-  freeIndices EntQueue  // 1-based as Ent#0 == NilEnt
+  // Number of bits of an Ent used for the generation; the rest is used for the index.
+  // Defaults to DefaultEntityGenerationBits when zero at the time of Init. Changing it
+  // after Init has no effect.
+  GenerationBits uint
+
+  // Recycled indices are only reused when at least this many are free.
+  // Defaults to MinimumFreeIndices when zero at the time of Init.
+  MinFreeIndices int
+
+  indexBits      uint   // entintSize - GenerationBits
+  indexMask      entint // 1 << indexBits - 1
+  generationMask entint // 1 << GenerationBits - 1
+  generation     []entint
+  freeIndices    EntQueue  // 1-based as Ent#0 == NilEnt
 }
 
 func (em *EntManager) Init() {
+  if em.GenerationBits == 0 {
+    em.GenerationBits = DefaultEntityGenerationBits
+  }
+  if em.GenerationBits >= entintSize {
+    panicf("EntManager.Init: GenerationBits must be in the range [1-%d]", entintSize - 1)
+  }
+  if em.MinFreeIndices <= 0 {
+    em.MinFreeIndices = MinimumFreeIndices
+  }
+  em.indexBits = entintSize - em.GenerationBits
+  em.indexMask = entint(1) << em.indexBits - 1
+  em.generationMask = entint(1) << em.GenerationBits - 1
   // pre-allocate freeIndices since we will use at least that many slots
-  em.generation = make([]byte, 1, MinimumFreeIndices + 1)  // 1-based
-  em.freeIndices.Items = make([]Ent, 0, MinimumFreeIndices + 1)[:]
+  em.generation = make([]entint, 1, em.MinFreeIndices + 1)  // 1-based
+  em.freeIndices.Init(em.MinFreeIndices + 1)
+}
+
+// Index returns the index part of e
+func (em *EntManager) Index(e Ent) entint {
+  return entint(e) & em.indexMask
+}
+
+// Generation returns the generation part of e
+func (em *EntManager) Generation(e Ent) entint {
+  return (entint(e) >> em.indexBits) & em.generationMask
+}
+
+func (em *EntManager) createEnt(index, generation entint) Ent {
+  return Ent(generation << em.indexBits | index)
 }
 
 func (em *EntManager) Alloc() Ent {
   var index entint
-  if em.freeIndices.Len() >= MinimumFreeIndices {
+  if em.freeIndices.Len() >= em.MinFreeIndices {
    index = entint(em.freeIndices.PopFront())
   } else {
+   index = entint(len(em.generation))
+   if index > em.indexMask {
+     panicf("EntManager.Alloc: out of entity indices (max %d)", em.indexMask)
+   }
    em.generation = append(em.generation, 0)
-   index = entint(len(em.generation)) - 1
-   // assert(index < (1 << EntityIndexBits))
   }
-  return createEnt(index, em.generation[index])
+  return em.createEnt(index, em.generation[index])
 }
 
 func (em *EntManager) Free(e Ent) {
-  index := e.Index()
-  em.generation[index]++
+  index := em.Index(e)
+  em.generation[index] = (em.generation[index] + 1) & em.generationMask
   em.freeIndices.PushBack(Ent(index))
 }
 
 func (em *EntManager) IsAlive(e Ent) bool {
-  return entint(e) != 0 && em.generation[e.Index()] == e.Generation()
+  index := em.Index(e)
+  return entint(e) != 0 &&
+         int(index) < len(em.generation) &&
+         em.generation[index] == em.Generation(e)
 }
 
 
 
-// double-ended queue
-// TODO: more efficient implementation, esp. for PopFront()
+// EntQueue is a double-ended queue implemented as a ring buffer.
+// The zero value is an empty queue ready to use.
 type EntQueue struct {
-  Items []Ent
+  items []Ent // len(items) is always zero or a power of two
+  head  int   // index of the front item
+  n     int   // number of items
+}
+
+// Init empties the queue and makes room for at least capacity items
+func (q *EntQueue) Init(capacity int) {
+  size := 1
+  for size < capacity {
+    size <<= 1
+  }
+  q.items = make([]Ent, size)
+  q.head = 0
+  q.n = 0
 }
 
 func (q *EntQueue) Len() int {
-  return len(q.Items)
+  return q.n
+}
+
+// At returns the i:th item, counting from the front
+func (q *EntQueue) At(i int) Ent {
+  if i < 0 || i >= q.n {
+    panicf("EntQueue.At: index %d out of range", i)
+  }
+  return q.items[(q.head + i) & (len(q.items) - 1)]
 }
 
 func (q *EntQueue) Front() Ent {
-  return q.Items[0]
+  return q.At(0)
 }
 
 func (q *EntQueue) Back() Ent {
-  return q.Items[len(q.Items)-1]
+  return q.At(q.n - 1)
 }
 
 func (q *EntQueue) PushBack(v Ent) {
-  q.Items = append(q.Items, v)
+  if q.n == len(q.items) {
+    q.grow()
+  }
+  q.items[(q.head + q.n) & (len(q.items) - 1)] = v
+  q.n++
 }
 
 func (q *EntQueue) PushFront(v Ent) {
-  q.Items = append(q.Items, v)
-  copy(q.Items[1:], q.Items[:len(q.Items)-1])
-  q.Items[0] = v
+  if q.n == len(q.items) {
+    q.grow()
+  }
+  q.head = (q.head - 1) & (len(q.items) - 1)
+  q.items[q.head] = v
+  q.n++
 }
 
 func (q *EntQueue) PopFront() Ent {
-  v := q.Items[0]
-  q.Items = q.Items[1:]
+  v := q.Front()
+  q.head = (q.head + 1) & (len(q.items) - 1)
+  q.n--
   return v
 }
 
 func (q *EntQueue) PopBack() Ent {
-  i := len(q.Items)-1
-  v := q.Items[i]
-  q.Items = q.Items[:i]
+  v := q.Back()
+  q.n--
   return v
 }
+
+// grow doubles the capacity, moving items to the beginning of the new buffer
+func (q *EntQueue) grow() {
+  items := make([]Ent, max(16, len(q.items) * 2))
+  if q.n > 0 {
+    i := copy(items, q.items[q.head:])
+    copy(items[i:], q.items[:q.head])
+  }
+  q.items = items
+  q.head = 0
+}
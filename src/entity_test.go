@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestEntManagerLayout(t *testing.T) {
+  for _, bits := range []uint{ 1, 8, 12 } {
+    em := &EntManager{ GenerationBits: bits, MinFreeIndices: 1 }
+    em.Init()
+    generations := entint(1) << bits
+
+    // the index is reused with a new generation until the generation wraps around
+    first := em.Alloc()
+    e := first
+    for gen := entint(0); gen < generations; gen++ {
+      if em.Index(e) != 1 || em.Generation(e) != gen {
+        t.Fatalf("bits %d: %#v has index %d and generation %d; expected 1 and %d",
+          bits, e, em.Index(e), em.Generation(e), gen)
+      }
+      if gen > 0 && e == first {
+        t.Fatalf("bits %d: ID reused after %d generations", bits, gen)
+      }
+      em.Free(e)
+      if em.IsAlive(e) {
+        t.Fatalf("bits %d: %#v alive after Free", bits, e)
+      }
+      e = em.Alloc()
+    }
+    if e != first {
+      t.Errorf("bits %d: generation did not wrap after %d generations", bits, generations)
+    }
+  }
+}
+
+func TestEntManagerInitValidates(t *testing.T) {
+  defer func() {
+    if recover() == nil {
+      t.Error("no panic for GenerationBits that leaves no index bits")
+    }
+  }()
+  em := &EntManager{ GenerationBits: entintSize }
+  em.Init()
+}
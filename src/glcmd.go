@@ -0,0 +1,266 @@
+package main
+
+import "math"
+
+// GLCommandBuffer encodes GL commands into linear memory so that they can be sent to
+// the host in one batch (see GLContext.flush), instead of making one host call per
+// command. The host decodes the commands and replays them against WebGL
+// (see glExec in host.js; DecodeGLCommands is the Go equivalent.)
+//
+// Format:
+//
+// A buffer is a sequence of little-endian 32-bit words. Each command starts with a
+// header word followed by the command's arguments:
+//
+//   header  u32   opcode (GLOp) in the low 16 bits, argument count in the high 16 bits
+//   args    argc × u32
+//
+// Arguments are unsigned or signed integers, floats (as IEEE 754 bits) or object
+// handles. Since JS objects can't be stored in linear memory, GL objects (buffers,
+// textures, programs and uniform locations) are referred to by id. The host keeps a table of
+// id => object, populated when objects are created (see GLContext.registerObject.)
+// Id 0 means null.
+//
+// Commands and their arguments:
+//
+//   GLOpViewport                  x i32, y i32, width i32, height i32
+//   GLOpClear                     mask u32
+//   GLOpClearColor                r f32, g f32, b f32, a f32
+//   GLOpClearDepth                depth f32
+//   GLOpEnable                    cap u32
+//   GLOpDisable                   cap u32
+//   GLOpDepthFunc                 func u32
+//   GLOpDepthMask                 flag u32
+//   GLOpBlendFunc                 sfactor u32, dfactor u32
+//   GLOpBindBuffer                target u32, buffer id
+//   GLOpVertexAttribPointer       index u32, size u32, type u32, normalized u32,
+//                                 stride u32, offset u32
+//   GLOpEnableVertexAttribArray   index u32
+//   GLOpDisableVertexAttribArray  index u32
+//   GLOpUseProgram                program id
+//   GLOpUniformMatrix             location id, transpose u32, 4, 9 or 16 × f32
+//   GLOpUniformf                  location id, 1-4 × f32
+//   GLOpUniformi                  location id, 1-4 × i32
+//   GLOpDrawArrays                mode u32, first u32, count u32
+//   GLOpDrawElements              mode u32, count u32, type u32, offset u32
+//   GLOpCullFace                  mode u32
+//   GLOpActiveTexture             texture u32 (GL_TEXTURE0 + unit)
+//   GLOpBindTexture               target u32, texture id
+//   GLOpTexParameteri             target u32, pname u32, param i32
+//
+type GLCommandBuffer struct {
+  words []uint32
+}
+
+// GLOp is the opcode of a GL command
+type GLOp uint16
+const (
+  GLOpNone = GLOp(iota)
+  GLOpViewport
+  GLOpClear
+  GLOpClearColor
+  GLOpClearDepth
+  GLOpEnable
+  GLOpDisable
+  GLOpDepthFunc
+  GLOpDepthMask
+  GLOpBlendFunc
+  GLOpBindBuffer
+  GLOpVertexAttribPointer
+  GLOpEnableVertexAttribArray
+  GLOpDisableVertexAttribArray
+  GLOpUseProgram
+  GLOpUniformMatrix
+  GLOpUniformf
+  GLOpUniformi
+  GLOpDrawArrays
+  GLOpDrawElements
+  GLOpCullFace
+  GLOpActiveTexture
+  GLOpBindTexture
+  GLOpTexParameteri
+  glOpCount
+)
+
+var glOpNames = [glOpCount]string{
+  "None",
+  "Viewport",
+  "Clear",
+  "ClearColor",
+  "ClearDepth",
+  "Enable",
+  "Disable",
+  "DepthFunc",
+  "DepthMask",
+  "BlendFunc",
+  "BindBuffer",
+  "VertexAttribPointer",
+  "EnableVertexAttribArray",
+  "DisableVertexAttribArray",
+  "UseProgram",
+  "UniformMatrix",
+  "Uniformf",
+  "Uniformi",
+  "DrawArrays",
+  "DrawElements",
+  "CullFace",
+  "ActiveTexture",
+  "BindTexture",
+  "TexParameteri",
+}
+
+func (op GLOp) String() string {
+  if op < glOpCount {
+    return glOpNames[op]
+  }
+  return "(GLOp?)"
+}
+
+// Reset empties the buffer, keeping its memory
+func (b *GLCommandBuffer) Reset() {
+  b.words = b.words[:0]
+}
+
+// Len returns the number of words in the buffer
+func (b *GLCommandBuffer) Len() int {
+  return len(b.words)
+}
+
+// Words returns the encoded commands.
+// The returned slice is only valid until the next command is added.
+func (b *GLCommandBuffer) Words() []uint32 {
+  return b.words
+}
+
+func (b *GLCommandBuffer) op(op GLOp, args ...uint32) {
+  b.words = append(b.words, uint32(op) | uint32(len(args)) << 16)
+  b.words = append(b.words, args...)
+}
+
+func (b *GLCommandBuffer) opf(op GLOp, prefix []uint32, args ...float32) {
+  b.words = append(b.words, uint32(op) | uint32(len(prefix) + len(args)) << 16)
+  b.words = append(b.words, prefix...)
+  for _, v := range args {
+    b.words = append(b.words, math.Float32bits(v))
+  }
+}
+
+func (b *GLCommandBuffer) Viewport(x, y, width, height int32) {
+  b.op(GLOpViewport, uint32(x), uint32(y), uint32(width), uint32(height))
+}
+
+func (b *GLCommandBuffer) Clear(mask uint32)               { b.op(GLOpClear, mask) }
+func (b *GLCommandBuffer) ClearColor(r, g, bl, a float32)  { b.opf(GLOpClearColor, nil, r, g, bl, a) }
+func (b *GLCommandBuffer) ClearDepth(d float32)            { b.opf(GLOpClearDepth, nil, d) }
+func (b *GLCommandBuffer) Enable(cap uint32)               { b.op(GLOpEnable, cap) }
+func (b *GLCommandBuffer) Disable(cap uint32)              { b.op(GLOpDisable, cap) }
+func (b *GLCommandBuffer) DepthFunc(fn uint32)             { b.op(GLOpDepthFunc, fn) }
+func (b *GLCommandBuffer) DepthMask(flag uint32)           { b.op(GLOpDepthMask, flag) }
+func (b *GLCommandBuffer) BlendFunc(sfactor, dfactor uint32) { b.op(GLOpBlendFunc, sfactor, dfactor) }
+func (b *GLCommandBuffer) BindBuffer(target, buffer uint32)  { b.op(GLOpBindBuffer, target, buffer) }
+
+func (b *GLCommandBuffer) VertexAttribPointer(index, size, typ, normalized, stride, offset uint32) {
+  b.op(GLOpVertexAttribPointer, index, size, typ, normalized, stride, offset)
+}
+
+func (b *GLCommandBuffer) EnableVertexAttribArray(index uint32) {
+  b.op(GLOpEnableVertexAttribArray, index)
+}
+
+func (b *GLCommandBuffer) DisableVertexAttribArray(index uint32) {
+  b.op(GLOpDisableVertexAttribArray, index)
+}
+
+func (b *GLCommandBuffer) UseProgram(program uint32) { b.op(GLOpUseProgram, program) }
+
+// UniformMatrix sets a 2x2, 3x3 or 4x4 matrix uniform; len(m) must be 4, 9 or 16
+func (b *GLCommandBuffer) UniformMatrix(location uint32, transpose uint32, m []float32) {
+  if len(m) != 4 && len(m) != 9 && len(m) != 16 {
+    panicf("GLCommandBuffer.UniformMatrix: invalid matrix size %d", len(m))
+  }
+  b.opf(GLOpUniformMatrix, []uint32{ location, transpose }, m...)
+}
+
+func (b *GLCommandBuffer) Uniformf(location uint32, v ...float32) {
+  if len(v) < 1 || len(v) > 4 {
+    panicf("GLCommandBuffer.Uniformf: invalid value count %d", len(v))
+  }
+  b.opf(GLOpUniformf, []uint32{ location }, v...)
+}
+
+func (b *GLCommandBuffer) Uniformi(location uint32, v ...int32) {
+  if len(v) < 1 || len(v) > 4 {
+    panicf("GLCommandBuffer.Uniformi: invalid value count %d", len(v))
+  }
+  b.words = append(b.words, uint32(GLOpUniformi) | uint32(1 + len(v)) << 16, location)
+  for _, x := range v {
+    b.words = append(b.words, uint32(x))
+  }
+}
+
+func (b *GLCommandBuffer) DrawArrays(mode, first, count uint32) {
+  b.op(GLOpDrawArrays, mode, first, count)
+}
+
+func (b *GLCommandBuffer) DrawElements(mode, count, typ, offset uint32) {
+  b.op(GLOpDrawElements, mode, count, typ, offset)
+}
+
+func (b *GLCommandBuffer) CullFace(mode uint32)                { b.op(GLOpCullFace, mode) }
+func (b *GLCommandBuffer) ActiveTexture(texture uint32)        { b.op(GLOpActiveTexture, texture) }
+func (b *GLCommandBuffer) BindTexture(target, texture uint32)  { b.op(GLOpBindTexture, target, texture) }
+
+func (b *GLCommandBuffer) TexParameteri(target, pname uint32, param int32) {
+  b.op(GLOpTexParameteri, target, pname, uint32(param))
+}
+
+// -----------------------------------------------------------------------------
+
+// DecodeGLCommands calls fn for each command encoded in words.
+// args is only valid during the call to fn. Use GLArgFloat to read float arguments.
+// Returns an error if words is malformed or a command has the wrong number of
+// arguments.
+func DecodeGLCommands(words []uint32, fn func(op GLOp, args []uint32)) error {
+  for i := 0; i < len(words); {
+    op := GLOp(words[i] & 0xffff)
+    argc := int(words[i] >> 16)
+    i++
+    if i + argc > len(words) {
+      return errorf("GL command %s at word %d: truncated", op, i - 1)
+    }
+    args := words[i : i + argc]
+    if !glValidArgc(op, argc) {
+      return errorf("GL command %s at word %d: invalid argument count %d", op, i - 1, argc)
+    }
+    fn(op, args)
+    i += argc
+  }
+  return nil
+}
+
+// GLArgFloat returns a float argument of a decoded command
+func GLArgFloat(v uint32) float32 {
+  return math.Float32frombits(v)
+}
+
+func glValidArgc(op GLOp, argc int) bool {
+  switch op {
+  case GLOpClear, GLOpClearDepth, GLOpEnable, GLOpDisable, GLOpDepthFunc, GLOpDepthMask,
+       GLOpEnableVertexAttribArray, GLOpDisableVertexAttribArray, GLOpUseProgram,
+       GLOpCullFace, GLOpActiveTexture:
+    return argc == 1
+  case GLOpBlendFunc, GLOpBindBuffer, GLOpBindTexture:
+    return argc == 2
+  case GLOpDrawArrays, GLOpTexParameteri:
+    return argc == 3
+  case GLOpViewport, GLOpClearColor, GLOpDrawElements:
+    return argc == 4
+  case GLOpVertexAttribPointer:
+    return argc == 6
+  case GLOpUniformMatrix:
+    return argc == 2 + 4 || argc == 2 + 9 || argc == 2 + 16
+  case GLOpUniformf, GLOpUniformi:
+    return argc >= 2 && argc <= 5
+  }
+  return false
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+  "math"
+  "testing"
+)
+
+func f32bits(v ...float32) []uint32 {
+  words := make([]uint32, len(v))
+  for i, f := range v {
+    words[i] = math.Float32bits(f)
+  }
+  return words
+}
+
+func TestGLCommandBufferRoundTrip(t *testing.T) {
+  nan := math.Float32frombits(0x7fc00123) // NaN with a payload, which must be kept
+  negZero := float32(math.Copysign(0, -1))
+  mat := make([]float32, 16)
+  for i := range mat {
+    mat[i] = float32(i) - 7.25
+  }
+  neg := func(v int32) uint32 { return uint32(v) }
+
+  tests := []struct {
+    op     GLOp
+    encode func(b *GLCommandBuffer)
+    args   []uint32
+  }{
+    { GLOpViewport, func(b *GLCommandBuffer) { b.Viewport(-1, -200, 640, 480) },
+      []uint32{ neg(-1), neg(-200), 640, 480 } },
+    { GLOpClear, func(b *GLCommandBuffer) { b.Clear(GL_COLOR_BUFFER_BIT) },
+      []uint32{ GL_COLOR_BUFFER_BIT } },
+    { GLOpClearColor, func(b *GLCommandBuffer) { b.ClearColor(0.1, negZero, nan, 1) },
+      f32bits(0.1, negZero, nan, 1) },
+    { GLOpClearDepth, func(b *GLCommandBuffer) { b.ClearDepth(1) }, f32bits(1) },
+    { GLOpEnable, func(b *GLCommandBuffer) { b.Enable(GL_DEPTH_TEST) },
+      []uint32{ GL_DEPTH_TEST } },
+    { GLOpDisable, func(b *GLCommandBuffer) { b.Disable(GL_BLEND) }, []uint32{ GL_BLEND } },
+    { GLOpDepthFunc, func(b *GLCommandBuffer) { b.DepthFunc(GL_LEQUAL) },
+      []uint32{ GL_LEQUAL } },
+    { GLOpDepthMask, func(b *GLCommandBuffer) { b.DepthMask(0) }, []uint32{ 0 } },
+    { GLOpBlendFunc, func(b *GLCommandBuffer) { b.BlendFunc(GL_SRC_ALPHA, GL_ONE_MINUS_SRC_ALPHA) },
+      []uint32{ GL_SRC_ALPHA, GL_ONE_MINUS_SRC_ALPHA } },
+    { GLOpBindBuffer, func(b *GLCommandBuffer) { b.BindBuffer(GL_ARRAY_BUFFER, 3) },
+      []uint32{ GL_ARRAY_BUFFER, 3 } },
+    { GLOpVertexAttribPointer,
+      func(b *GLCommandBuffer) { b.VertexAttribPointer(1, 3, GL_FLOAT, 0, 32, 12) },
+      []uint32{ 1, 3, GL_FLOAT, 0, 32, 12 } },
+    { GLOpEnableVertexAttribArray, func(b *GLCommandBuffer) { b.EnableVertexAttribArray(2) },
+      []uint32{ 2 } },
+    { GLOpDisableVertexAttribArray, func(b *GLCommandBuffer) { b.DisableVertexAttribArray(2) },
+      []uint32{ 2 } },
+    { GLOpUseProgram, func(b *GLCommandBuffer) { b.UseProgram(9) }, []uint32{ 9 } },
+    { GLOpUniformMatrix, func(b *GLCommandBuffer) { b.UniformMatrix(5, 0, mat) },
+      append([]uint32{ 5, 0 }, f32bits(mat...)...) },
+    { GLOpUniformMatrix, func(b *GLCommandBuffer) { b.UniformMatrix(5, 1, mat[:4]) },
+      append([]uint32{ 5, 1 }, f32bits(mat[:4]...)...) },
+    { GLOpUniformf, func(b *GLCommandBuffer) { b.Uniformf(6, -0.5) },
+      append([]uint32{ 6 }, f32bits(-0.5)...) },
+    { GLOpUniformf, func(b *GLCommandBuffer) { b.Uniformf(6, 1, 2, nan, negZero) },
+      append([]uint32{ 6 }, f32bits(1, 2, nan, negZero)...) },
+    { GLOpUniformi, func(b *GLCommandBuffer) { b.Uniformi(7, -7) },
+      []uint32{ 7, neg(-7) } },
+    { GLOpUniformi, func(b *GLCommandBuffer) { b.Uniformi(7, 1, -2, math.MinInt32, math.MaxInt32) },
+      []uint32{ 7, 1, neg(-2), neg(math.MinInt32), math.MaxInt32 } },
+    { GLOpDrawArrays, func(b *GLCommandBuffer) { b.DrawArrays(GL_TRIANGLES, 0, 36) },
+      []uint32{ GL_TRIANGLES, 0, 36 } },
+    { GLOpDrawElements, func(b *GLCommandBuffer) { b.DrawElements(GL_TRIANGLES, 6, GL_UNSIGNED_SHORT, 12) },
+      []uint32{ GL_TRIANGLES, 6, GL_UNSIGNED_SHORT, 12 } },
+    { GLOpCullFace, func(b *GLCommandBuffer) { b.CullFace(GL_FRONT) }, []uint32{ GL_FRONT } },
+    { GLOpActiveTexture, func(b *GLCommandBuffer) { b.ActiveTexture(GL_TEXTURE0 + 1) },
+      []uint32{ GL_TEXTURE0 + 1 } },
+    { GLOpBindTexture, func(b *GLCommandBuffer) { b.BindTexture(GL_TEXTURE_2D, 4) },
+      []uint32{ GL_TEXTURE_2D, 4 } },
+    { GLOpTexParameteri, func(b *GLCommandBuffer) { b.TexParameteri(GL_TEXTURE_2D, GL_TEXTURE_WRAP_S, -2) },
+      []uint32{ GL_TEXTURE_2D, GL_TEXTURE_WRAP_S, neg(-2) } },
+  }
+
+  // all commands in one buffer, to also check that they are delimited correctly
+  var b GLCommandBuffer
+  covered := make([]bool, glOpCount)
+  for _, test := range tests {
+    test.encode(&b)
+    covered[test.op] = true
+  }
+  for op := GLOpNone + 1; op < glOpCount; op++ {
+    if !covered[op] {
+      t.Errorf("no test of %s", op)
+    }
+  }
+
+  i := 0
+  err := DecodeGLCommands(b.Words(), func(op GLOp, args []uint32) {
+    if i >= len(tests) {
+      t.Fatalf("extra command %s", op)
+    }
+    test := tests[i]
+    i++
+    if op != test.op {
+      t.Errorf("command %d: decoded %s, expected %s", i - 1, op, test.op)
+      return
+    }
+    if len(args) != len(test.args) {
+      t.Errorf("%s: decoded %d args, expected %d", op, len(args), len(test.args))
+      return
+    }
+    for j, v := range args {
+      if v != test.args[j] {
+        t.Errorf("%s: arg %d is %#x, expected %#x", op, j, v, test.args[j])
+      }
+    }
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  if i != len(tests) {
+    t.Errorf("decoded %d commands, expected %d", i, len(tests))
+  }
+  if f := GLArgFloat(f32bits(nan)[0]); math.Float32bits(f) != 0x7fc00123 {
+    t.Errorf("GLArgFloat changed NaN bits to %#x", math.Float32bits(f))
+  }
+}
+
+func TestDecodeGLCommandsMalformed(t *testing.T) {
+  var b GLCommandBuffer
+  b.Viewport(0, 0, 1, 1)
+  words := b.Words()
+  noop := func(GLOp, []uint32) {}
+  if DecodeGLCommands(words[:len(words) - 1], noop) == nil {
+    t.Error("no error for truncated command")
+  }
+  if DecodeGLCommands([]uint32{ uint32(GLOpClear) | 2 << 16, 0, 0 }, noop) == nil {
+    t.Error("no error for wrong argument count")
+  }
+  if DecodeGLCommands([]uint32{ uint32(glOpCount) }, noop) == nil {
+    t.Error("no error for unknown command")
+  }
+}
@@ -0,0 +1,343 @@
+package main
+
+// See https://www.khronos.org/registry/OpenGL/api/GLES/gl.h
+
+type GLenum     = uint32
+type GLboolean  = uint8
+type GLbitfield = uint32
+type GLbyte     = int8
+type GLshort    = int16
+type GLint      = int32
+type GLsizei    = int32
+type GLubyte    = uint8
+type GLushort   = uint16
+type GLuint     = uint32
+type GLfloat    = float32
+type GLclampf   = float32
+type GLintptr   = int32
+type GLsizeiptr = int32
+type GLfixed    = int32
+type GLclampx   = int32
+
+
+/*  ------------------------------------------------------------------------------
+Reminder of this source file are GL constants, generated from the following
+javascript snippet, run in a browser:
+
+console.log(((o)=>
+  Object.keys(o)
+    .filter(k => { let c = k.charCodeAt(); return c >= 0x41 && c <= 0x5A })
+    .sort((a, b) => {
+      const s = "TEXTURE"  // e.g. TEXTURE2, TEXTURE5, TEXTURE21 etc.
+      if (a.length > s.length && a.length <= s.length + 2 && a.startsWith(s)) {
+        let an = parseInt(a.substr(s.length))
+        let bn = parseInt(b.substr(s.length))
+        if (!isNaN(an) && !isNaN(bn)) {
+          return an < bn ? -1 : bn < an ? 1 : 0
+        }
+      }
+      return a < b ? -1 : b < a ? 1 : 0
+    })
+    .map(k => `  ${k} = GLenum(${o[k]})`)
+    .join("\n")
+)(WebGLRenderingContext.prototype))
+
+*/
+var (
+  GL_ACTIVE_ATTRIBUTES = GLenum(35721)
+  GL_ACTIVE_TEXTURE = GLenum(34016)
+  GL_ACTIVE_UNIFORMS = GLenum(35718)
+  GL_ALIASED_LINE_WIDTH_RANGE = GLenum(33902)
+  GL_ALIASED_POINT_SIZE_RANGE = GLenum(33901)
+  GL_ALPHA = GLenum(6406)
+  GL_ALPHA_BITS = GLenum(3413)
+  GL_ALWAYS = GLenum(519)
+  GL_ARRAY_BUFFER = GLenum(34962)
+  GL_ARRAY_BUFFER_BINDING = GLenum(34964)
+  GL_ATTACHED_SHADERS = GLenum(35717)
+  GL_BACK = GLenum(1029)
+  GL_BLEND = GLenum(3042)
+  GL_BLEND_COLOR = GLenum(32773)
+  GL_BLEND_DST_ALPHA = GLenum(32970)
+  GL_BLEND_DST_RGB = GLenum(32968)
+  GL_BLEND_EQUATION = GLenum(32777)
+  GL_BLEND_EQUATION_ALPHA = GLenum(34877)
+  GL_BLEND_EQUATION_RGB = GLenum(32777)
+  GL_BLEND_SRC_ALPHA = GLenum(32971)
+  GL_BLEND_SRC_RGB = GLenum(32969)
+  GL_BLUE_BITS = GLenum(3412)
+  GL_BOOL = GLenum(35670)
+  GL_BOOL_VEC2 = GLenum(35671)
+  GL_BOOL_VEC3 = GLenum(35672)
+  GL_BOOL_VEC4 = GLenum(35673)
+  GL_BROWSER_DEFAULT_WEBGL = GLenum(37444)
+  GL_BUFFER_SIZE = GLenum(34660)
+  GL_BUFFER_USAGE = GLenum(34661)
+  GL_BYTE = GLenum(5120)
+  GL_CCW = GLenum(2305)
+  GL_CLAMP_TO_EDGE = GLenum(33071)
+  GL_COLOR_ATTACHMENT0 = GLenum(36064)
+  GL_COLOR_BUFFER_BIT = GLenum(16384)
+  GL_COLOR_CLEAR_VALUE = GLenum(3106)
+  GL_COLOR_WRITEMASK = GLenum(3107)
+  GL_COMPILE_STATUS = GLenum(35713)
+  GL_COMPRESSED_TEXTURE_FORMATS = GLenum(34467)
+  GL_CONSTANT_ALPHA = GLenum(32771)
+  GL_CONSTANT_COLOR = GLenum(32769)
+  GL_CONTEXT_LOST_WEBGL = GLenum(37442)
+  GL_CULL_FACE = GLenum(2884)
+  GL_CULL_FACE_MODE = GLenum(2885)
+  GL_CURRENT_PROGRAM = GLenum(35725)
+  GL_CURRENT_VERTEX_ATTRIB = GLenum(34342)
+  GL_CW = GLenum(2304)
+  GL_DECR = GLenum(7683)
+  GL_DECR_WRAP = GLenum(34056)
+  GL_DELETE_STATUS = GLenum(35712)
+  GL_DEPTH_ATTACHMENT = GLenum(36096)
+  GL_DEPTH_BITS = GLenum(3414)
+  GL_DEPTH_BUFFER_BIT = GLenum(256)
+  GL_DEPTH_CLEAR_VALUE = GLenum(2931)
+  GL_DEPTH_COMPONENT = GLenum(6402)
+  GL_DEPTH_COMPONENT16 = GLenum(33189)
+  GL_DEPTH_FUNC = GLenum(2932)
+  GL_DEPTH_RANGE = GLenum(2928)
+  GL_DEPTH_STENCIL = GLenum(34041)
+  GL_DEPTH_STENCIL_ATTACHMENT = GLenum(33306)
+  GL_DEPTH_TEST = GLenum(2929)
+  GL_DEPTH_WRITEMASK = GLenum(2930)
+  GL_DITHER = GLenum(3024)
+  GL_DONT_CARE = GLenum(4352)
+  GL_DST_ALPHA = GLenum(772)
+  GL_DST_COLOR = GLenum(774)
+  GL_DYNAMIC_DRAW = GLenum(35048)
+  GL_ELEMENT_ARRAY_BUFFER = GLenum(34963)
+  GL_ELEMENT_ARRAY_BUFFER_BINDING = GLenum(34965)
+  GL_EQUAL = GLenum(514)
+  GL_FASTEST = GLenum(4353)
+  GL_FLOAT = GLenum(5126)
+  GL_FLOAT_MAT2 = GLenum(35674)
+  GL_FLOAT_MAT3 = GLenum(35675)
+  GL_FLOAT_MAT4 = GLenum(35676)
+  GL_FLOAT_VEC2 = GLenum(35664)
+  GL_FLOAT_VEC3 = GLenum(35665)
+  GL_FLOAT_VEC4 = GLenum(35666)
+  GL_FRAGMENT_SHADER = GLenum(35632)
+  GL_FRAMEBUFFER = GLenum(36160)
+  GL_FRAMEBUFFER_ATTACHMENT_OBJECT_NAME = GLenum(36049)
+  GL_FRAMEBUFFER_ATTACHMENT_OBJECT_TYPE = GLenum(36048)
+  GL_FRAMEBUFFER_ATTACHMENT_TEXTURE_CUBE_MAP_FACE = GLenum(36051)
+  GL_FRAMEBUFFER_ATTACHMENT_TEXTURE_LEVEL = GLenum(36050)
+  GL_FRAMEBUFFER_BINDING = GLenum(36006)
+  GL_FRAMEBUFFER_COMPLETE = GLenum(36053)
+  GL_FRAMEBUFFER_INCOMPLETE_ATTACHMENT = GLenum(36054)
+  GL_FRAMEBUFFER_INCOMPLETE_DIMENSIONS = GLenum(36057)
+  GL_FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT = GLenum(36055)
+  GL_FRAMEBUFFER_UNSUPPORTED = GLenum(36061)
+  GL_FRONT = GLenum(1028)
+  GL_FRONT_AND_BACK = GLenum(1032)
+  GL_FRONT_FACE = GLenum(2886)
+  GL_FUNC_ADD = GLenum(32774)
+  GL_FUNC_REVERSE_SUBTRACT = GLenum(32779)
+  GL_FUNC_SUBTRACT = GLenum(32778)
+  GL_GENERATE_MIPMAP_HINT = GLenum(33170)
+  GL_GEQUAL = GLenum(518)
+  GL_GREATER = GLenum(516)
+  GL_GREEN_BITS = GLenum(3411)
+  GL_HIGH_FLOAT = GLenum(36338)
+  GL_HIGH_INT = GLenum(36341)
+  GL_IMPLEMENTATION_COLOR_READ_FORMAT = GLenum(35739)
+  GL_IMPLEMENTATION_COLOR_READ_TYPE = GLenum(35738)
+  GL_INCR = GLenum(7682)
+  GL_INCR_WRAP = GLenum(34055)
+  GL_INT = GLenum(5124)
+  GL_INT_VEC2 = GLenum(35667)
+  GL_INT_VEC3 = GLenum(35668)
+  GL_INT_VEC4 = GLenum(35669)
+  GL_INVALID_ENUM = GLenum(1280)
+  GL_INVALID_FRAMEBUFFER_OPERATION = GLenum(1286)
+  GL_INVALID_OPERATION = GLenum(1282)
+  GL_INVALID_VALUE = GLenum(1281)
+  GL_INVERT = GLenum(5386)
+  GL_KEEP = GLenum(7680)
+  GL_LEQUAL = GLenum(515)
+  GL_LESS = GLenum(513)
+  GL_LINEAR = GLenum(9729)
+  GL_LINEAR_MIPMAP_LINEAR = GLenum(9987)
+  GL_LINEAR_MIPMAP_NEAREST = GLenum(9985)
+  GL_LINES = GLenum(1)
+  GL_LINE_LOOP = GLenum(2)
+  GL_LINE_STRIP = GLenum(3)
+  GL_LINE_WIDTH = GLenum(2849)
+  GL_LINK_STATUS = GLenum(35714)
+  GL_LOW_FLOAT = GLenum(36336)
+  GL_LOW_INT = GLenum(36339)
+  GL_LUMINANCE = GLenum(6409)
+  GL_LUMINANCE_ALPHA = GLenum(6410)
+  GL_MAX_COMBINED_TEXTURE_IMAGE_UNITS = GLenum(35661)
+  GL_MAX_CUBE_MAP_TEXTURE_SIZE = GLenum(34076)
+  GL_MAX_FRAGMENT_UNIFORM_VECTORS = GLenum(36349)
+  GL_MAX_RENDERBUFFER_SIZE = GLenum(34024)
+  GL_MAX_TEXTURE_IMAGE_UNITS = GLenum(34930)
+  GL_MAX_TEXTURE_SIZE = GLenum(3379)
+  GL_MAX_VARYING_VECTORS = GLenum(36348)
+  GL_MAX_VERTEX_ATTRIBS = GLenum(34921)
+  GL_MAX_VERTEX_TEXTURE_IMAGE_UNITS = GLenum(35660)
+  GL_MAX_VERTEX_UNIFORM_VECTORS = GLenum(36347)
+  GL_MAX_VIEWPORT_DIMS = GLenum(3386)
+  GL_MEDIUM_FLOAT = GLenum(36337)
+  GL_MEDIUM_INT = GLenum(36340)
+  GL_MIRRORED_REPEAT = GLenum(33648)
+  GL_NEAREST = GLenum(9728)
+  GL_NEAREST_MIPMAP_LINEAR = GLenum(9986)
+  GL_NEAREST_MIPMAP_NEAREST = GLenum(9984)
+  GL_NEVER = GLenum(512)
+  GL_NICEST = GLenum(4354)
+  GL_NONE = GLenum(0)
+  GL_NOTEQUAL = GLenum(517)
+  GL_NO_ERROR = GLenum(0)
+  GL_ONE = GLenum(1)
+  GL_ONE_MINUS_CONSTANT_ALPHA = GLenum(32772)
+  GL_ONE_MINUS_CONSTANT_COLOR = GLenum(32770)
+  GL_ONE_MINUS_DST_ALPHA = GLenum(773)
+  GL_ONE_MINUS_DST_COLOR = GLenum(775)
+  GL_ONE_MINUS_SRC_ALPHA = GLenum(771)
+  GL_ONE_MINUS_SRC_COLOR = GLenum(769)
+  GL_OUT_OF_MEMORY = GLenum(1285)
+  GL_PACK_ALIGNMENT = GLenum(3333)
+  GL_POINTS = GLenum(0)
+  GL_POLYGON_OFFSET_FACTOR = GLenum(32824)
+  GL_POLYGON_OFFSET_FILL = GLenum(32823)
+  GL_POLYGON_OFFSET_UNITS = GLenum(10752)
+  GL_RED_BITS = GLenum(3410)
+  GL_RENDERBUFFER = GLenum(36161)
+  GL_RENDERBUFFER_ALPHA_SIZE = GLenum(36179)
+  GL_RENDERBUFFER_BINDING = GLenum(36007)
+  GL_RENDERBUFFER_BLUE_SIZE = GLenum(36178)
+  GL_RENDERBUFFER_DEPTH_SIZE = GLenum(36180)
+  GL_RENDERBUFFER_GREEN_SIZE = GLenum(36177)
+  GL_RENDERBUFFER_HEIGHT = GLenum(36163)
+  GL_RENDERBUFFER_INTERNAL_FORMAT = GLenum(36164)
+  GL_RENDERBUFFER_RED_SIZE = GLenum(36176)
+  GL_RENDERBUFFER_STENCIL_SIZE = GLenum(36181)
+  GL_RENDERBUFFER_WIDTH = GLenum(36162)
+  GL_RENDERER = GLenum(7937)
+  GL_REPEAT = GLenum(10497)
+  GL_REPLACE = GLenum(7681)
+  GL_RGB = GLenum(6407)
+  GL_RGB565 = GLenum(36194)
+  GL_RGB5_A1 = GLenum(32855)
+  GL_RGBA = GLenum(6408)
+  GL_RGBA4 = GLenum(32854)
+  GL_SAMPLER_2D = GLenum(35678)
+  GL_SAMPLER_CUBE = GLenum(35680)
+  GL_SAMPLES = GLenum(32937)
+  GL_SAMPLE_ALPHA_TO_COVERAGE = GLenum(32926)
+  GL_SAMPLE_BUFFERS = GLenum(32936)
+  GL_SAMPLE_COVERAGE = GLenum(32928)
+  GL_SAMPLE_COVERAGE_INVERT = GLenum(32939)
+  GL_SAMPLE_COVERAGE_VALUE = GLenum(32938)
+  GL_SCISSOR_BOX = GLenum(3088)
+  GL_SCISSOR_TEST = GLenum(3089)
+  GL_SHADER_TYPE = GLenum(35663)
+  GL_SHADING_LANGUAGE_VERSION = GLenum(35724)
+  GL_SHORT = GLenum(5122)
+  GL_SRC_ALPHA = GLenum(770)
+  GL_SRC_ALPHA_SATURATE = GLenum(776)
+  GL_SRC_COLOR = GLenum(768)
+  GL_STATIC_DRAW = GLenum(35044)
+  GL_STENCIL_ATTACHMENT = GLenum(36128)
+  GL_STENCIL_BACK_FAIL = GLenum(34817)
+  GL_STENCIL_BACK_FUNC = GLenum(34816)
+  GL_STENCIL_BACK_PASS_DEPTH_FAIL = GLenum(34818)
+  GL_STENCIL_BACK_PASS_DEPTH_PASS = GLenum(34819)
+  GL_STENCIL_BACK_REF = GLenum(36003)
+  GL_STENCIL_BACK_VALUE_MASK = GLenum(36004)
+  GL_STENCIL_BACK_WRITEMASK = GLenum(36005)
+  GL_STENCIL_BITS = GLenum(3415)
+  GL_STENCIL_BUFFER_BIT = GLenum(1024)
+  GL_STENCIL_CLEAR_VALUE = GLenum(2961)
+  GL_STENCIL_FAIL = GLenum(2964)
+  GL_STENCIL_FUNC = GLenum(2962)
+  GL_STENCIL_INDEX8 = GLenum(36168)
+  GL_STENCIL_PASS_DEPTH_FAIL = GLenum(2965)
+  GL_STENCIL_PASS_DEPTH_PASS = GLenum(2966)
+  GL_STENCIL_REF = GLenum(2967)
+  GL_STENCIL_TEST = GLenum(2960)
+  GL_STENCIL_VALUE_MASK = GLenum(2963)
+  GL_STENCIL_WRITEMASK = GLenum(2968)
+  GL_STREAM_DRAW = GLenum(35040)
+  GL_SUBPIXEL_BITS = GLenum(3408)
+  GL_TEXTURE = GLenum(5890)
+  GL_TEXTURE0 = GLenum(33984)
+  GL_TEXTURE1 = GLenum(33985)
+  GL_TEXTURE2 = GLenum(33986)
+  GL_TEXTURE3 = GLenum(33987)
+  GL_TEXTURE4 = GLenum(33988)
+  GL_TEXTURE5 = GLenum(33989)
+  GL_TEXTURE6 = GLenum(33990)
+  GL_TEXTURE7 = GLenum(33991)
+  GL_TEXTURE8 = GLenum(33992)
+  GL_TEXTURE9 = GLenum(33993)
+  GL_TEXTURE10 = GLenum(33994)
+  GL_TEXTURE11 = GLenum(33995)
+  GL_TEXTURE12 = GLenum(33996)
+  GL_TEXTURE13 = GLenum(33997)
+  GL_TEXTURE14 = GLenum(33998)
+  GL_TEXTURE15 = GLenum(33999)
+  GL_TEXTURE16 = GLenum(34000)
+  GL_TEXTURE17 = GLenum(34001)
+  GL_TEXTURE18 = GLenum(34002)
+  GL_TEXTURE19 = GLenum(34003)
+  GL_TEXTURE20 = GLenum(34004)
+  GL_TEXTURE21 = GLenum(34005)
+  GL_TEXTURE22 = GLenum(34006)
+  GL_TEXTURE23 = GLenum(34007)
+  GL_TEXTURE24 = GLenum(34008)
+  GL_TEXTURE25 = GLenum(34009)
+  GL_TEXTURE26 = GLenum(34010)
+  GL_TEXTURE27 = GLenum(34011)
+  GL_TEXTURE28 = GLenum(34012)
+  GL_TEXTURE29 = GLenum(34013)
+  GL_TEXTURE30 = GLenum(34014)
+  GL_TEXTURE31 = GLenum(34015)
+  GL_TEXTURE_2D = GLenum(3553)
+  GL_TEXTURE_BINDING_2D = GLenum(32873)
+  GL_TEXTURE_BINDING_CUBE_MAP = GLenum(34068)
+  GL_TEXTURE_CUBE_MAP = GLenum(34067)
+  GL_TEXTURE_CUBE_MAP_NEGATIVE_X = GLenum(34070)
+  GL_TEXTURE_CUBE_MAP_NEGATIVE_Y = GLenum(34072)
+  GL_TEXTURE_CUBE_MAP_NEGATIVE_Z = GLenum(34074)
+  GL_TEXTURE_CUBE_MAP_POSITIVE_X = GLenum(34069)
+  GL_TEXTURE_CUBE_MAP_POSITIVE_Y = GLenum(34071)
+  GL_TEXTURE_CUBE_MAP_POSITIVE_Z = GLenum(34073)
+  GL_TEXTURE_MAG_FILTER = GLenum(10240)
+  GL_TEXTURE_MIN_FILTER = GLenum(10241)
+  GL_TEXTURE_WRAP_S = GLenum(10242)
+  GL_TEXTURE_WRAP_T = GLenum(10243)
+  GL_TRIANGLES = GLenum(4)
+  GL_TRIANGLE_FAN = GLenum(6)
+  GL_TRIANGLE_STRIP = GLenum(5)
+  GL_UNPACK_ALIGNMENT = GLenum(3317)
+  GL_UNPACK_COLORSPACE_CONVERSION_WEBGL = GLenum(37443)
+  GL_UNPACK_FLIP_Y_WEBGL = GLenum(37440)
+  GL_UNPACK_PREMULTIPLY_ALPHA_WEBGL = GLenum(37441)
+  GL_UNSIGNED_BYTE = GLenum(5121)
+  GL_UNSIGNED_INT = GLenum(5125)
+  GL_UNSIGNED_SHORT = GLenum(5123)
+  GL_UNSIGNED_SHORT_4_4_4_4 = GLenum(32819)
+  GL_UNSIGNED_SHORT_5_5_5_1 = GLenum(32820)
+  GL_UNSIGNED_SHORT_5_6_5 = GLenum(33635)
+  GL_VALIDATE_STATUS = GLenum(35715)
+  GL_VENDOR = GLenum(7936)
+  GL_VERSION = GLenum(7938)
+  GL_VERTEX_ATTRIB_ARRAY_BUFFER_BINDING = GLenum(34975)
+  GL_VERTEX_ATTRIB_ARRAY_ENABLED = GLenum(34338)
+  GL_VERTEX_ATTRIB_ARRAY_NORMALIZED = GLenum(34922)
+  GL_VERTEX_ATTRIB_ARRAY_POINTER = GLenum(34373)
+  GL_VERTEX_ATTRIB_ARRAY_SIZE = GLenum(34339)
+  GL_VERTEX_ATTRIB_ARRAY_STRIDE = GLenum(34340)
+  GL_VERTEX_ATTRIB_ARRAY_TYPE = GLenum(34341)
+  GL_VERTEX_SHADER = GLenum(35633)
+  GL_VIEWPORT = GLenum(2978)
+  GL_ZERO = GLenum(0)
+)
@@ -1,13 +1,12 @@
 package main
 
 
+// GLCube is a Drawable cube with corners at -1 and 1
 type GLCube struct {
-  program           *GLProgram
+  material          *Material
   vertexBuf         *GLBuf
   indexBuf          *GLBuf
   aVertexPosition   uint32
-  uModelViewMatrix  GLUniform
-  absoluteTransform Matrix4
 }
 
 var cubeVertices = GLVertexData(GL_STATIC_DRAW, []float32{
@@ -58,26 +57,29 @@ var cubeVertices = GLVertexData(GL_STATIC_DRAW, []float32{
 )
 
 
-func NewGLCube(program *GLProgram) (*GLCube, error) {
-	gl := program.gl
+// NewGLCube returns a cube drawn with material, whose program must declare the
+// aVertexPosition attribute
+func NewGLCube(material *Material) (*GLCube, error) {
+	gl := material.Program.dev
 	o := &GLCube{
-		program: program,
-		vertexBuf: gl.GetVertexBuffer(cubeVertices),
-		indexBuf: gl.GetIndexBuffer(cubeVertices),
-		absoluteTransform: Matrix4Identity,
+		material: material,
+		vertexBuf: GetVertexBuffer(gl, cubeVertices),
+		indexBuf: GetIndexBuffer(gl, cubeVertices),
 	}
 	// get shader positions
 	var err error
-	o.aVertexPosition, err = program.getAttribLocation("aVertexPosition")
-	if err == nil {
-		o.uModelViewMatrix, err = program.getUniformLocation("uModelViewMatrix")
-	}
+	o.aVertexPosition, err = material.Program.getAttribLocation("aVertexPosition")
 	return o, err
 }
 
 
-func (o *GLCube) Draw(r *Renderer) {
-	gl := o.program.gl
+func (o *GLCube) Material() *Material {
+	return o.material
+}
+
+
+func (o *GLCube) Draw(r *Renderer, modelView *Matrix4) {
+	gl := o.material.Program.dev
 
   // Tell WebGL how to pull out the positions from the position
   // buffer into the vertexPosition attribute
@@ -95,27 +97,5 @@ func (o *GLCube) Draw(r *Renderer) {
   // Tell WebGL which indices to use to index the vertices
   gl.bindBuffer(GL_ELEMENT_ARRAY_BUFFER, o.indexBuf.pos)
 
-	// enable program
-	gl.useProgram(o.program)
-
-
-  // set model view matrix
-	tm := o.absoluteTransform
-
-	time := float32(host.scenetime)
-  // tm = tm.WithRotation(time, 0.0, 0.0, 1.0)
-  // tm = tm.WithRotation(time, 0, 1, 0.5)
-  tm.Translate(sin32(time*0.5) * 1.0, cos32(time) * 1.5, 0.0)
-  tm.Rotate(sin32(time * 0.8), cos32(time * 0.5), time * 2)
-
-  // tm.RotateX((r.pointer[1] / r.resolution[1]) * PI)
-  tm.RotateY((r.pointer[0] / r.resolution[0]) * PI)
-  tm.RotateZ((r.pointer[1] / r.resolution[1]) * PI)
-
-  tm.Scale(0.2 + abs32(sin32(time)), 0.2 + abs32(cos32(time)), 0.5)
-
-  gl.uniformMatrix4fv(o.uModelViewMatrix, false, tm)
-
-
   gl.drawElements(GL_TRIANGLES, /*vertexCount*/ 36, GL_UNSIGNED_SHORT, /*offset*/ 0)
 }
@@ -1,12 +1,12 @@
 package main
 
 
+// GLPlane is a Drawable 2x2 square in the XY plane, colored by its shader based on
+// the pointer position and time.
 type GLPlane struct {
-	program           *GLProgram
+	material          *Material
 	buf               *GLBuf
 	aVertexPosition   uint32
-	uModelViewMatrix  GLUniform
-	absoluteTransform Matrix4     // TODO: replace with TransformNode in ECS
 }
 
 var planeVertices = GLVertexData(GL_STATIC_DRAW, []float32{
@@ -17,35 +17,35 @@ var planeVertices = GLVertexData(GL_STATIC_DRAW, []float32{
 })
 
 
-func NewGLPlane(program *GLProgram) (*GLPlane, error) {
-	gl := program.gl
-	buf := gl.GetVertexBuffer(planeVertices)
+// NewGLPlane returns a plane drawn with material, whose program must declare the
+// aVertexPosition attribute
+func NewGLPlane(material *Material) (*GLPlane, error) {
+	gl := material.Program.dev
+	buf := GetVertexBuffer(gl, planeVertices)
 
 	// posbuf := gl.createBuffer()
 	// gl.bindBuffer(GL_ARRAY_BUFFER, posbuf)
 	// gl.bufferDataF32(GL_ARRAY_BUFFER, planeVertices, GL_STATIC_DRAW)
 
 	o := &GLPlane{
-		program: program,
+		material: material,
 		buf: buf,
-		absoluteTransform: Matrix4Identity,  // identity; the center of the scene
 	}
 
-	// move back a little away from the camera
-	o.absoluteTransform.TranslateZ(-5)
-
 	// get shader positions
 	var err error
-	o.aVertexPosition, err = program.getAttribLocation("aVertexPosition")
-	if err == nil {
-		o.uModelViewMatrix, err = program.getUniformLocation("uModelViewMatrix")
-	}
+	o.aVertexPosition, err = material.Program.getAttribLocation("aVertexPosition")
 	return o, err
 }
 
 
-func (o *GLPlane) Draw(r *Renderer) {
-	gl := o.program.gl
+func (o *GLPlane) Material() *Material {
+	return o.material
+}
+
+
+func (o *GLPlane) Draw(r *Renderer, modelView *Matrix4) {
+	gl := o.material.Program.dev
 
 	// activate vertex buffer
 	gl.bindBuffer(GL_ARRAY_BUFFER, o.buf.pos)
@@ -59,15 +59,6 @@ func (o *GLPlane) Draw(r *Renderer) {
   )
   gl.enableVertexAttribArray(o.aVertexPosition)
 
-	// enable program
-	gl.useProgram(o.program)
-
-  // set model view matrix
-	tm := o.absoluteTransform
-  tm.RotateY((r.pointer[0] / r.resolution[0]) * PI)
-  tm.RotateZ((r.pointer[1] / r.resolution[1]) * PI)
-  gl.uniformMatrix4fv(o.uModelViewMatrix, false, tm)
-
   // draw
   gl.drawArrays(GL_TRIANGLE_STRIP, /*offset*/ 0, /*vertexCount*/ 4)
 }
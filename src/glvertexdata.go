@@ -0,0 +1,138 @@
+package main
+
+// GetVertexBuffer returns the vertex buffer of ref on dev
+func GetVertexBuffer(dev GraphicsDevice, ref GLVertexDataRef) *GLBuf {
+  return &initGLVertexData(dev)[ref].vertexBuf
+}
+
+// GetIndexBuffer returns the index buffer of ref on dev
+func GetIndexBuffer(dev GraphicsDevice, ref GLVertexDataRef) *GLBuf {
+  return &initGLVertexData(dev)[ref].indexBuf
+}
+
+type GLBuf struct {
+  pos    GLBuffer // underlying buffer position
+  offset uint32   // offset into underlying buffer
+}
+
+// --------------------------------
+
+// GLVertexData is a registry and source of buffer data.
+//
+// Intended use:
+// 1. One-time initializer calls GLVertexData() with its data and stores the
+//    returned GLVertexDataRef.
+// 2. Instance constructor calls GetVertexBuffer(dev, GLVertexDataRef) to retrieve
+//    a GLBuf handle for the actual underlying buffer.
+// 3. Draw function passes GLBuf.pos to gl.bindBuffer()
+//    and GLBuf.offset to e.g. vertexAttribPointer().
+//
+// Buffers are created separately for each device the first time they are requested.
+//
+type GLVertexDataRef uint32
+type glVertexData struct {
+  usage uint32          // e.g. GL_STATIC_DRAW
+  vertexData []float32  // input vertex data
+  indexData  []uint16   // input index data
+}
+
+// glVertexBufs holds the buffers of a glVertexData on a device
+type glVertexBufs struct {
+  vertexBuf  GLBuf
+  indexBuf   GLBuf
+}
+
+var glVertexDataMap []glVertexData
+
+// glVertexBufsMap holds the buffers created for each device by initGLVertexData
+var glVertexBufsMap = make(map[GraphicsDevice][]glVertexBufs)
+
+func GLVertexData(usage uint32, vertexData []float32, index ...uint16) GLVertexDataRef {
+  ref := GLVertexDataRef(len(glVertexDataMap))
+  glVertexDataMap = append(glVertexDataMap, glVertexData{
+    usage: usage,
+    vertexData: vertexData,
+    indexData: index,
+  })
+  return ref
+}
+
+// initGLVertexData creates buffers on dev for all registered vertex data, unless
+// they have already been created. Returns buffers indexed by GLVertexDataRef.
+func initGLVertexData(dev GraphicsDevice) []glVertexBufs {
+  bufs := glVertexBufsMap[dev]
+  if len(bufs) == len(glVertexDataMap) {
+    return bufs
+  }
+  bufs = make([]glVertexBufs, len(glVertexDataMap))
+  glVertexBufsMap[dev] = bufs
+
+  // sort vertexData into categories of usage, in order of first use so that
+  // buffers are created in the same order each time
+  groups := make(map[uint32][]int, len(glVertexDataMap)/2)
+  var usages []uint32
+  for i := 0; i < len(glVertexDataMap); i++ {
+    d := &glVertexDataMap[i]
+    if _, ok := groups[d.usage]; !ok {
+      usages = append(usages, d.usage)
+    }
+    groups[d.usage] = append(groups[d.usage], i)
+  }
+
+  for _, usage := range usages {
+    v := groups[usage]
+    // calculate size of buffer
+    vertexDataSize := 0
+    indexDataSize := 0
+    for _, i := range v {
+      vertexDataSize += len(glVertexDataMap[i].vertexData)
+      indexDataSize += len(glVertexDataMap[i].indexData)
+    }
+
+    // allocate GL buffer to get position
+    vertexBuf := dev.createBuffer()
+    indexBuf := dev.createBuffer()
+
+    // build one contiguous array of all data and update glVertexBufs
+    vertexData := make([]float32, vertexDataSize)
+    vertexOffset := uint32(0)
+    indexData := make([]uint16, indexDataSize)
+    indexOffset := uint32(0)
+
+    for _, i := range v {
+      d, b := &glVertexDataMap[i], &bufs[i]
+      b.vertexBuf.offset = vertexOffset * 4  // offset is in bytes; sizeof(float32)=4
+      b.vertexBuf.pos = vertexBuf
+      copy(vertexData[vertexOffset:], d.vertexData)
+      vertexOffset += uint32(len(d.vertexData))
+
+      if len(d.indexData) > 0 {
+        b.indexBuf.offset = indexOffset * 2  // offset is in bytes; sizeof(uint16)=2
+        b.indexBuf.pos = indexBuf
+        copy(indexData[indexOffset:], d.indexData)
+        indexOffset += uint32(len(d.indexData))
+      }
+    }
+
+    // TODO: compress vertexData slab:
+    //
+    // 1  for each glVertexData d:
+    // 2    let offset be the first match of d.vertexData in vertexData
+    // 3    if offset != sparse_offset then:
+    // 4      splice out sparse_offset+len from vertexData
+    //
+    // Note: #2 is essentially substring search
+    //
+
+    // copy vertexData to GL buffer
+    dev.bindBuffer(GL_ARRAY_BUFFER, vertexBuf)
+    dev.bufferDataF32(GL_ARRAY_BUFFER, vertexData, usage)
+
+    // copy indexData to GL buffer
+    if indexDataSize > 0 {
+      dev.bindBuffer(GL_ELEMENT_ARRAY_BUFFER, indexBuf)
+      dev.bufferDataU16(GL_ELEMENT_ARRAY_BUFFER, indexData, usage)
+    }
+  }
+  return bufs
+}
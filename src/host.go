@@ -1,3 +1,5 @@
+// +build js,wasm
+
 package main
 
 import (
@@ -62,6 +64,8 @@ const (
   HGLuniformvi = uint32(1017)
   HGLdrawArrays = uint32(1018)
   HGLdrawElements = uint32(1019)
+  HGLexec = uint32(1024) // ([]u32) -> () executes a GLCommandBuffer
+  HGLtexImage2D = uint32(1025) // (u32,u32,u32,[]uint8) -> ()
 )
 
 // Events
@@ -73,6 +77,9 @@ const (
   EVPointerDown
   EVPointerUp
   EVAnimationFrame
+  EVWheel
+  EVKey
+  EVWindowBlur
 )
 
 func (e Event) String() string {
@@ -82,6 +89,9 @@ func (e Event) String() string {
   case EVPointerDown:    return "EVPointerDown"
   case EVPointerUp:      return "EVPointerUp"
   case EVAnimationFrame: return "EVAnimationFrame"
+  case EVWheel:          return "EVWheel"
+  case EVKey:            return "EVKey"
+  case EVWindowBlur:     return "EVWindowBlur"
   default:               return "(EV?)"
   }
 }
@@ -171,6 +181,38 @@ func CreateHostEnv(jsv js.Value) *HostEnv {
 }
 
 
+// ConnectInput makes in track the host's primary pointer, wheel and keyboard
+func (h *HostEnv) ConnectInput(in *Input) {
+  onPointerEvent := func (_ Event, _ ...uint32) {
+    in.PointerMoved(h.pointer.x, h.pointer.y, h.pointer.buttons)
+  }
+  h.events.Listen(EVPointerMove, onPointerEvent)
+  h.events.Listen(EVPointerDown, onPointerEvent)
+  h.events.Listen(EVPointerUp, onPointerEvent)
+
+  h.events.Listen(EVWheel, func (_ Event, data ...uint32) {
+    // data = [ deltaX, deltaY ] as int32 display points * 10 (see host.js)
+    in.WheelMoved(float32(int32(data[0])) / 10, float32(int32(data[1])) / 10)
+  })
+
+  // data = key codes of all keys pressed or released since the last frame, in the
+  // order the events occurred. Bit 31 is set for keys pressed (see host.js)
+  h.events.Listen(EVKey, func (_ Event, keys ...uint32) {
+    for _, key := range keys {
+      in.SetKey(Key(key &^ evKeyDownBit), key & evKeyDownBit != 0)
+    }
+  })
+
+  // key releases are not seen while the window doesn't have focus
+  h.events.Listen(EVWindowBlur, func (_ Event, _ ...uint32) {
+    in.ReleaseKeys()
+  })
+}
+
+// evKeyDownBit is set in EVKey data for keys pressed
+const evKeyDownBit = uint32(1) << 31
+
+
 func (h *HostEnv) eventSubscribe(ev Event) {
   hostcall_u32_(HEventSubscribe, uint32(ev))
 }
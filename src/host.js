@@ -47,6 +47,8 @@ const HEventSubscribe   = uint32(2)
     , HGLuniformvi = uint32(1017)
     , HGLdrawArrays = uint32(1018)
     , HGLdrawElements = uint32(1019)
+    , HGLexec = uint32(1024) // ([]u32) -> () executes a GLCommandBuffer
+    , HGLtexImage2D = uint32(1025) // (u32,u32,u32,[]uint8) -> ()
 
 // Event IDs
 const EVNone           = 0
@@ -55,6 +57,9 @@ const EVNone           = 0
     , EVPointerDown    = 3
     , EVPointerUp      = 4
     , EVAnimationFrame = 5
+    , EVWheel          = 6
+    , EVKey            = 7
+    , EVWindowBlur     = 8
 
 function EVString(ev) {
   switch (ev) {
@@ -63,6 +68,9 @@ function EVString(ev) {
   case EVPointerDown:    return "EVPointerDown"
   case EVPointerUp:      return "EVPointerUp"
   case EVAnimationFrame: return "EVAnimationFrame"
+  case EVWheel:          return "EVWheel"
+  case EVKey:            return "EVKey"
+  case EVWindowBlur:     return "EVWindowBlur"
   default:               return "(EV?)"
   }
 }
@@ -100,12 +108,12 @@ function regHCall(sig, msg, handler) {
 //   host.eventSubscribe(evs)
 // })
 
-regHCall("u32_", HEventSubscribe, (mem, events) => {
-  host.eventSubscribe(events)
+regHCall("u32_", HEventSubscribe, (mem, ev) => {
+  host.eventSubscribe(ev)
 })
 
-regHCall("u32_", HEventUnsubscribe, (mem, events) => {
-  host.eventUnsubscribe(events)
+regHCall("u32_", HEventUnsubscribe, (mem, ev) => {
+  host.eventUnsubscribe(ev)
 })
 
 regHCall("__", HAnimationStatsUpdate, mem => {
@@ -211,6 +219,19 @@ regHCall("jvu32_", HGLbufferData, (mem, gl, argc, argaddr) => {
   gl.bufferData(target, new Uint8Array(mem.buf, dataaddr, datasize), usage)
 })
 
+regHCall("jvu32_", HGLtexImage2D, (mem, gl, argc, argaddr) => {
+  // Sets the image of the texture bound to target to RGBA pixels, 8 bits per channel,
+  // read directly from Go memory (see HGLbufferData)
+  assert(argc == 5)
+  const target   = mem.getUint32(argaddr)
+  const width    = mem.getUint32(argaddr + 4)
+  const height   = mem.getUint32(argaddr + 8)
+  const dataaddr = mem.getUint32(argaddr + 12)  // address of data in gomem
+  const datasize = mem.getUint32(argaddr + 16)  // size of data in bytes
+  gl.texImage2D(target, 0, gl.RGBA, width, height, 0, gl.RGBA, gl.UNSIGNED_BYTE,
+    new Uint8Array(mem.buf, dataaddr, datasize))
+})
+
 regHCall("jvu32_", HGLvertexAttribPointer, (mem, gl, argc, argaddr) => {
   assert(argc == 6)
   const index      = mem.getUint32(argaddr)
@@ -284,6 +305,128 @@ regHCall("jx2_", HGLuseProgram, (mem, gl, program) => {
   gl.useProgram(program)
 })
 
+// GL command buffer, encoded by GLCommandBuffer in glcmd.go (see there for the format.)
+// Opcodes must match GLOp in glcmd.go.
+const GLOpViewport                 = 1
+    , GLOpClear                    = 2
+    , GLOpClearColor               = 3
+    , GLOpClearDepth               = 4
+    , GLOpEnable                   = 5
+    , GLOpDisable                  = 6
+    , GLOpDepthFunc                = 7
+    , GLOpDepthMask                = 8
+    , GLOpBlendFunc                = 9
+    , GLOpBindBuffer               = 10
+    , GLOpVertexAttribPointer      = 11
+    , GLOpEnableVertexAttribArray  = 12
+    , GLOpDisableVertexAttribArray = 13
+    , GLOpUseProgram               = 14
+    , GLOpUniformMatrix            = 15
+    , GLOpUniformf                 = 16
+    , GLOpUniformi                 = 17
+    , GLOpDrawArrays               = 18
+    , GLOpDrawElements             = 19
+    , GLOpCullFace                 = 20
+    , GLOpActiveTexture            = 21
+    , GLOpBindTexture              = 22
+    , GLOpTexParameteri            = 23
+
+// GL objects referred to by id from GL commands (see Host.glSetObject)
+const glObjects = new Map()
+
+function glObject(id) {
+  return id == 0 ? null : glObjects.get(id)
+}
+
+regHCall("jvu32_", HGLexec, (mem, gl, argc, argaddr) => {
+  const words = new Uint32Array(mem.buf, argaddr, argc)
+  const floats = new Float32Array(mem.buf, argaddr, argc)
+  const ints = new Int32Array(mem.buf, argaddr, argc)
+  for (let i = 0; i < argc; ) {
+    const op = words[i] & 0xFFFF
+    const n = words[i] >>> 16
+    const a = ++i  // index of first argument
+    i += n
+    switch (op) {
+    case GLOpViewport:
+      gl.viewport(ints[a], ints[a+1], ints[a+2], ints[a+3]); break
+    case GLOpClear:
+      gl.clear(words[a]); break
+    case GLOpClearColor:
+      gl.clearColor(floats[a], floats[a+1], floats[a+2], floats[a+3]); break
+    case GLOpClearDepth:
+      gl.clearDepth(floats[a]); break
+    case GLOpEnable:
+      gl.enable(words[a]); break
+    case GLOpDisable:
+      gl.disable(words[a]); break
+    case GLOpDepthFunc:
+      gl.depthFunc(words[a]); break
+    case GLOpDepthMask:
+      gl.depthMask(words[a] != 0); break
+    case GLOpBlendFunc:
+      gl.blendFunc(words[a], words[a+1]); break
+    case GLOpBindBuffer:
+      gl.bindBuffer(words[a], glObject(words[a+1])); break
+    case GLOpVertexAttribPointer:
+      gl.vertexAttribPointer(
+        words[a], words[a+1], words[a+2], words[a+3] != 0, words[a+4], words[a+5])
+      break
+    case GLOpEnableVertexAttribArray:
+      gl.enableVertexAttribArray(words[a]); break
+    case GLOpDisableVertexAttribArray:
+      gl.disableVertexAttribArray(words[a]); break
+    case GLOpUseProgram:
+      gl.useProgram(glObject(words[a])); break
+    case GLOpUniformMatrix: {
+      const location = glObject(words[a])
+      const transpose = words[a+1] != 0
+      const value = floats.subarray(a + 2, i)
+      switch (value.length) {
+        case 4:  gl.uniformMatrix2fv(location, transpose, value); break
+        case 9:  gl.uniformMatrix3fv(location, transpose, value); break
+        case 16: gl.uniformMatrix4fv(location, transpose, value); break
+      }
+      break
+    }
+    case GLOpUniformf: {
+      const location = glObject(words[a])
+      switch (n - 1) {
+        case 1: gl.uniform1f(location, floats[a+1]); break
+        case 2: gl.uniform2f(location, floats[a+1], floats[a+2]); break
+        case 3: gl.uniform3f(location, floats[a+1], floats[a+2], floats[a+3]); break
+        case 4: gl.uniform4f(location, floats[a+1], floats[a+2], floats[a+3], floats[a+4]); break
+      }
+      break
+    }
+    case GLOpUniformi: {
+      const location = glObject(words[a])
+      switch (n - 1) {
+        case 1: gl.uniform1i(location, ints[a+1]); break
+        case 2: gl.uniform2i(location, ints[a+1], ints[a+2]); break
+        case 3: gl.uniform3i(location, ints[a+1], ints[a+2], ints[a+3]); break
+        case 4: gl.uniform4i(location, ints[a+1], ints[a+2], ints[a+3], ints[a+4]); break
+      }
+      break
+    }
+    case GLOpDrawArrays:
+      gl.drawArrays(words[a], words[a+1], words[a+2]); break
+    case GLOpDrawElements:
+      gl.drawElements(words[a], words[a+1], words[a+2], words[a+3]); break
+    case GLOpCullFace:
+      gl.cullFace(words[a]); break
+    case GLOpActiveTexture:
+      gl.activeTexture(words[a]); break
+    case GLOpBindTexture:
+      gl.bindTexture(words[a], glObject(words[a+1])); break
+    case GLOpTexParameteri:
+      gl.texParameteri(words[a], words[a+1], ints[a+2]); break
+    default:
+      throw new Error(`HGLexec: invalid GL command ${op} at word ${a - 1}`)
+    }
+  }
+})
+
 
 // -----------------------------------------------------------------------------------
 // Go memory interface
@@ -528,6 +671,16 @@ const host = new class Host {
   }
 
 
+  // glSetObject adds a GL object to the table of objects referred to by GL commands
+  glSetObject(id, obj) {
+    glObjects.set(id, obj)
+  }
+
+  glDeleteObject(id) {
+    glObjects.delete(id)
+  }
+
+
   getContext(canvas, contextType) {
     return canvas.getContext(contextType)
     // let g = canvas.getContext(contextType)
@@ -562,12 +715,17 @@ const host = new class Host {
   initEvents() {
     const h = this
 
-    const jsEvent = (obj, name, handler) => ({
+    // names is one or more space-separated DOM event names.
+    // handler returns the data of the event, or null to ignore it.
+    // merge, when provided, combines the data of events that occur within the same
+    // frame: merge(prevData, data) => data. Without merge, only the last event is sent.
+    const jsEvent = (obj, names, handler, merge) => ({
       ev: 0,
       _jsevent: true,
       handler,
-      enable() { obj.addEventListener(name, this.handler) },
-      disable() { obj.removeEventListener(name, this.handler) },
+      merge,
+      enable() { names.split(" ").forEach(name => obj.addEventListener(name, this.handler)) },
+      disable() { names.split(" ").forEach(name => obj.removeEventListener(name, this.handler)) },
     })
 
     const persistentEvent = (ev, data) => ({
@@ -580,12 +738,39 @@ const host = new class Host {
     // Note: Changing this requires changes to host.go
     const handlePointerEvent = ev => [ ev.pointerId, ev.x*10, ev.y*10, ev.buttons ]
 
+    // handleWheelEvent translates a WheelEvent into [deltaX, deltaY] in display
+    // points*10. Values are int32 (negative values wrap around when written as uint32.)
+    // Note: Changing this requires changes to host.go
+    const handleWheelEvent = ev => {
+      let scale = 10
+      if (ev.deltaMode == 1) {  // DOM_DELTA_LINE
+        scale *= 16
+      } else if (ev.deltaMode == 2) {  // DOM_DELTA_PAGE
+        scale *= window.innerHeight
+      }
+      return [ Math.round(ev.deltaX * scale), Math.round(ev.deltaY * scale) ]
+    }
+    const mergeWheelEvents = (a, b) => [ a[0] + b[0], a[1] + b[1] ]
+
+    // handleKeyEvent translates a keydown or keyup KeyboardEvent into [keyCode], with
+    // bit 31 set for keydown, ignoring auto-repeat. Key events of a frame are merged
+    // into one list in the order they occurred, so that a key pressed and released
+    // within a frame ends up released. At most 32 are kept, the latest (see
+    // MAX_VALCOUNT in host.go)
+    // Note: Changing this requires changes to host.go
+    const handleKeyEvent = ev =>
+      ev.repeat ? null : [ ev.type == "keydown" ? (ev.keyCode | 0x80000000) >>> 0 : ev.keyCode ]
+    const mergeKeyEvents = (a, b) => a.concat(b).slice(-32)
+
     // all supported events
     h.events = {
       [EVPointerMove]:  jsEvent(window, "pointermove", handlePointerEvent),
       [EVPointerDown]:  jsEvent(window, "pointerdown", handlePointerEvent),
       [EVPointerUp]:    jsEvent(window, "pointerup",   handlePointerEvent),
       [EVWindowResize]: jsEvent(window, "resize", ev => [window.innerWidth, window.innerHeight] ),
+      [EVWheel]:        jsEvent(window, "wheel",   handleWheelEvent, mergeWheelEvents),
+      [EVKey]:          jsEvent(window, "keydown keyup", handleKeyEvent, mergeKeyEvents),
+      [EVWindowBlur]:   jsEvent(window, "blur", ev => []),
 
       [EVAnimationFrame]: persistentEvent(EVAnimationFrame),
     }
@@ -595,38 +780,41 @@ const host = new class Host {
       if (e._jsevent) {
         let handler = e.handler
         e.handler = (jsevent) => {
-          h.eventEnqueue(ev, handler(jsevent))
+          let data = handler(jsevent)
+          if (data !== null) {
+            h.eventEnqueue(ev, data, e.merge)
+          }
         }
       }
     }
   }
 
-  eventSubscribe(events) {
-    log("host.eventSubscribe", events, EVString(events))
-    for (let k in this.events) {
-      let e = this.events[k]
-      if (events & e.ev && !this.eventSubscriptions.has(e.ev)) {
-        this.eventSubscriptions.add(e.ev)
-        e.enable()
-      }
+  eventSubscribe(ev) {
+    log("host.eventSubscribe", ev, EVString(ev))
+    let e = this.events[ev]
+    if (e && !this.eventSubscriptions.has(ev)) {
+      this.eventSubscriptions.add(ev)
+      e.enable()
     }
   }
 
-  eventUnsubscribe(events) {
-    log("host.eventUnsubscribe", events, EVString(events))
-    for (let e of this.events) {
-      if (events & e.ev && this.eventSubMask.has(e.ev)) {
-        e.disable()
-        this.eventSubscriptions.delete(e.ev)
-        this.transientEvents.delete(e.ev)
-        this.persistentEvents.delete(e.ev)
-      }
+  eventUnsubscribe(ev) {
+    log("host.eventUnsubscribe", ev, EVString(ev))
+    let e = this.events[ev]
+    if (e && this.eventSubscriptions.has(ev)) {
+      e.disable()
+      this.eventSubscriptions.delete(ev)
+      this.transientEvents.delete(ev)
+      this.persistentEvents.delete(ev)
     }
   }
 
-  eventEnqueue(ev, data) {
+  eventEnqueue(ev, data, merge) {
     // log(`eventEnqueue ${EVString(ev)}`, data)
     assert(!data || data instanceof Array)
+    if (merge && this.transientEvents.has(ev)) {
+      data = merge(this.transientEvents.get(ev), data)
+    }
     this.transientEvents.set(ev, data)
     if (this.runloopWake) {
       this.runloopWakeA()
@@ -0,0 +1,87 @@
+package main
+
+// Key identifies a keyboard key by its DOM KeyboardEvent.keyCode
+type Key uint32
+const (
+  KeyShift = Key(16)
+  KeySpace = Key(32)
+  KeyLeft  = Key(37)
+  KeyUp    = Key(38)
+  KeyRight = Key(39)
+  KeyDown  = Key(40)
+  KeyA     = Key(65)
+  KeyD     = Key(68)
+  KeyE     = Key(69)
+  KeyQ     = Key(81)
+  KeyS     = Key(83)
+  KeyW     = Key(87)
+)
+
+// Pointer buttons, as in Input.Buttons
+const (
+  ButtonPrimary   = uint32(1)
+  ButtonSecondary = uint32(2)
+  ButtonMiddle    = uint32(4)
+)
+
+// Input is the state of the pointer, wheel and keyboard as seen by systems during
+// World.Update. It's fed by the host (see HostEnv.ConnectInput) or by calling its
+// methods, e.g. to drive a world without a browser.
+// Movement and Wheel accumulate between updates and are reset at the end of each
+// World.Update.
+type Input struct {
+  Pointer  Vec2   // pointer position in display points
+  Buttons  uint32 // bitmask of pressed pointer buttons, e.g. ButtonPrimary
+  Movement Vec2   // pointer movement since the last update, in display points
+  Wheel    Vec2   // wheel movement since the last update, in display points
+
+  keys       map[Key]bool // pressed keys
+  hasPointer bool         // true once Pointer is known
+}
+
+// PointerMoved records the pointer at x,y with buttons pressed
+func (in *Input) PointerMoved(x, y float32, buttons uint32) {
+  p := Vec2{ x, y }
+  if in.hasPointer {
+    in.Movement = in.Movement.Add(p.Sub(in.Pointer))
+  }
+  in.Pointer = p
+  in.Buttons = buttons
+  in.hasPointer = true
+}
+
+// WheelMoved records wheel movement. Positive dy means scrolling down.
+func (in *Input) WheelMoved(dx, dy float32) {
+  in.Wheel = in.Wheel.Add(Vec2{ dx, dy })
+}
+
+// SetKey records that key was pressed (down=true) or released
+func (in *Input) SetKey(key Key, down bool) {
+  if down {
+    if in.keys == nil {
+      in.keys = make(map[Key]bool)
+    }
+    in.keys[key] = true
+  } else {
+    delete(in.keys, key)
+  }
+}
+
+// ReleaseKeys records that all keys were released, e.g. when the window loses focus
+// and key releases can no longer be observed
+func (in *Input) ReleaseKeys() {
+  for key := range in.keys {
+    delete(in.keys, key)
+  }
+}
+
+// KeyDown returns true if key is pressed
+func (in *Input) KeyDown(key Key) bool {
+  return in.keys[key]
+}
+
+// endFrame resets movement that is accumulated between updates
+func (in *Input) endFrame() {
+  in.Movement = Vec2{}
+  in.Wheel = Vec2{}
+}
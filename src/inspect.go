@@ -0,0 +1,242 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "reflect"
+  "sort"
+  "strings"
+)
+
+// Inspect writes a human-readable description of the world to out: every entity
+// with a transform, name, tags or registered component, the values of its components
+// (formatted with %v, so a String method is used when available) and the transform
+// tree with local and absolute matrices.
+//
+// Example output:
+//
+//   World time 2.5 sim 2.483 ticks 149 alpha 0.3 paused false
+//   Entities (2)
+//     Ent#1 "player"
+//       movement  {mass 1, pos [0 0], vel [0 0], accel [0 0]}
+//     Ent#2 "player/gun" [weapon]
+//   Transforms
+//     player Ent#1
+//       local    [1 0 0 0] [0 1 0 0] [0 0 1 0] [0 0 0 1]
+//       absolute [1 0 0 0] [0 1 0 0] [0 0 1 0] [0 0 0 1]
+//       gun Ent#2
+//         ...
+//
+// Matrices are printed as rows.
+func (w *World) Inspect(out io.Writer) error {
+  var b strings.Builder
+  c := &w.Clock
+  fmt.Fprintf(&b, "World time %g sim %g ticks %d alpha %.3g paused %v\n",
+    w.Time, c.Time, c.Ticks, c.Alpha, c.Paused)
+
+  ents := w.inspectEnts()
+  fmt.Fprintf(&b, "Entities (%d)\n", len(ents))
+  for _, e := range ents {
+    fmt.Fprintf(&b, "  %v", e)
+    if w.Names.Has(e) || w.TransformSystem.Get(e) != nil {
+      fmt.Fprintf(&b, " %q", w.Names.Path(e))
+    }
+    if tags := w.Tags.Names(w.Tags.Get(e)); len(tags) > 0 {
+      fmt.Fprintf(&b, " [%s]", strings.Join(tags, " "))
+    }
+    b.WriteByte('\n')
+    for _, ct := range w.components {
+      if p := ct.Store.Get(e); p != nil {
+        fmt.Fprintf(&b, "    %-9s %v\n", ct.Name, reflect.ValueOf(p).Elem().Interface())
+      }
+    }
+  }
+
+  b.WriteString("Transforms\n")
+  for _, e := range w.inspectRoots() {
+    w.inspectNode(&b, e, "  ")
+  }
+  _, err := io.WriteString(out, b.String())
+  return err
+}
+
+// inspectNode writes the subtree of e. Nodes are looked up with TransformSystem.node
+// since entities freed without World.Destroy are still linked into the tree; those are
+// written as "<dead ent>".
+func (w *World) inspectNode(b *strings.Builder, e Ent, indent string) {
+  n := w.TransformSystem.node(e)
+  name := "<dead ent>"
+  if w.Ents.IsAlive(e) {
+    if name = w.Names.Get(e); name == "" {
+      name = "-"
+    }
+  }
+  fmt.Fprintf(b, "%s%s %v\n", indent, name, e)
+  fmt.Fprintf(b, "%s  local    %s\n", indent, formatMatrix4(n.Local()))
+  fmt.Fprintf(b, "%s  absolute %s\n", indent, formatMatrix4(n.Absolute()))
+  for child := n.firstChild; child != NilEnt; child = w.TransformSystem.node(child).nextSibling {
+    w.inspectNode(b, child, indent + "  ")
+  }
+}
+
+func formatMatrix4(m Matrix4) string {
+  var b strings.Builder
+  for row := 0; row < 4; row++ {
+    if row > 0 {
+      b.WriteByte(' ')
+    }
+    // Matrix4 is column-major
+    fmt.Fprintf(&b, "[%g %g %g %g]", m[row], m[row+4], m[row+8], m[row+12])
+  }
+  return b.String()
+}
+
+// inspectEnts returns all live entities that have a transform, name, tags or a
+// registered component, ordered by index
+func (w *World) inspectEnts() []Ent {
+  seen := make(map[Ent]bool)
+  var ents []Ent
+  stores := []*ComponentStore{
+    &w.TransformSystem.store,
+    &w.Names.ComponentStore,
+    &w.Tags.ComponentStore,
+  }
+  for _, ct := range w.components {
+    stores = append(stores, ct.Store)
+  }
+  for _, store := range stores {
+    for _, e := range store.Ents() {
+      if !seen[e] && w.Ents.IsAlive(e) {
+        seen[e] = true
+        ents = append(ents, e)
+      }
+    }
+  }
+  sort.Slice(ents, func(i, j int) bool { return w.Ents.Index(ents[i]) < w.Ents.Index(ents[j]) })
+  return ents
+}
+
+// inspectRoots returns the roots of the transform tree, ordered by index
+func (w *World) inspectRoots() []Ent {
+  var roots []Ent
+  for i, e := range w.TransformSystem.store.Ents() {
+    if w.TransformSystem.nodes[i].parent == NilEnt && w.Ents.IsAlive(e) {
+      roots = append(roots, e)
+    }
+  }
+  sort.Slice(roots, func(i, j int) bool { return w.Ents.Index(roots[i]) < w.Ents.Index(roots[j]) })
+  return roots
+}
+
+// -----------------------------------------------------------------------------
+// JSON format
+//
+//   {
+//     "time": 2.5,
+//     "clock": { "time": 2.483, "ticks": 149, "alpha": 0.3, "paused": false, "scale": 1 },
+//     "entities": [
+//       { "ent": "Ent#1", "index": 1, "generation": 0, "name": "player", "path": "player",
+//         "tags": ["controllable"], "components": { "movement": { ... } } },
+//     ],
+//     "transforms": [
+//       { "ent": "Ent#1", "name": "player", "local": [16 floats], "absolute": [16 floats],
+//         "children": [ ... ] }
+//     ]
+//   }
+//
+// Matrices are column-major, like Matrix4. Component values are encoded with
+// encoding/json, so a MarshalJSON method is used when available.
+
+type inspectJSON struct {
+  Time       float64               `json:"time"`
+  Clock      inspectClockJSON      `json:"clock"`
+  Entities   []inspectEntityJSON   `json:"entities"`
+  Transforms []inspectTransformJSON `json:"transforms"`
+}
+
+type inspectClockJSON struct {
+  Time   float64 `json:"time"`
+  Ticks  uint64  `json:"ticks"`
+  Alpha  float64 `json:"alpha"`
+  Paused bool    `json:"paused"`
+  Scale  float64 `json:"scale"`
+}
+
+type inspectEntityJSON struct {
+  Ent        string                 `json:"ent"`
+  Index      entint                 `json:"index"`
+  Generation entint                 `json:"generation"`
+  Name       string                 `json:"name,omitempty"`
+  Path       string                 `json:"path,omitempty"`
+  Tags       []string               `json:"tags,omitempty"`
+  Components map[string]interface{} `json:"components,omitempty"`
+}
+
+type inspectTransformJSON struct {
+  Ent      string                 `json:"ent"`
+  Name     string                 `json:"name,omitempty"`
+  Dead     bool                   `json:"dead,omitempty"` // freed without World.Destroy
+  Local    Matrix4                `json:"local"`
+  Absolute Matrix4                `json:"absolute"`
+  Children []inspectTransformJSON `json:"children,omitempty"`
+}
+
+// InspectJSON writes the same information as Inspect to out, as JSON
+func (w *World) InspectJSON(out io.Writer) error {
+  c := &w.Clock
+  doc := inspectJSON{
+    Time:       w.Time,
+    Clock:      inspectClockJSON{ c.Time, c.Ticks, c.Alpha, c.Paused, c.Scale },
+    Entities:   []inspectEntityJSON{},
+    Transforms: []inspectTransformJSON{},
+  }
+  for _, e := range w.inspectEnts() {
+    je := inspectEntityJSON{
+      Ent:        e.String(),
+      Index:      w.Ents.Index(e),
+      Generation: w.Ents.Generation(e),
+      Name:       w.Names.Get(e),
+      Tags:       w.Tags.Names(w.Tags.Get(e)),
+    }
+    if w.Names.Has(e) || w.TransformSystem.Get(e) != nil {
+      je.Path = w.Names.Path(e)
+    }
+    for _, ct := range w.components {
+      if p := ct.Store.Get(e); p != nil {
+        if je.Components == nil {
+          je.Components = make(map[string]interface{})
+        }
+        je.Components[ct.Name] = p
+      }
+    }
+    doc.Entities = append(doc.Entities, je)
+  }
+  for _, e := range w.inspectRoots() {
+    doc.Transforms = append(doc.Transforms, w.inspectNodeJSON(e))
+  }
+  data, err := json.MarshalIndent(&doc, "", "  ")
+  if err != nil {
+    return err
+  }
+  _, err = out.Write(append(data, '\n'))
+  return err
+}
+
+func (w *World) inspectNodeJSON(e Ent) inspectTransformJSON {
+  n := w.TransformSystem.node(e) // see inspectNode
+  jn := inspectTransformJSON{
+    Ent:      e.String(),
+    Local:    n.Local(),
+    Absolute: n.Absolute(),
+  }
+  if w.Ents.IsAlive(e) {
+    jn.Name = w.Names.Get(e)
+  } else {
+    jn.Dead = true
+  }
+  for child := n.firstChild; child != NilEnt; child = w.TransformSystem.node(child).nextSibling {
+    jn.Children = append(jn.Children, w.inspectNodeJSON(child))
+  }
+  return jn
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "strings"
+  "testing"
+)
+
+// The inspector must not crash on entities freed with Ents.Free instead of
+// World.Destroy, which are still linked into the transform tree
+func TestInspectFreedEntities(t *testing.T) {
+  w := newTestWorld()
+  root, freed, grandchild, sibling := w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc()
+  for _, e := range []Ent{ root, freed, grandchild, sibling } {
+    w.CreateNode(e, Matrix4Identity)
+  }
+  w.Names.SetName(root, "root")
+  w.Names.SetName(grandchild, "grandchild")
+  w.Names.SetName(sibling, "sibling")
+  w.AppendChild(root, freed)
+  w.AppendChild(root, sibling)
+  w.AppendChild(freed, grandchild)
+  w.Ents.Free(freed)
+
+  var b bytes.Buffer
+  if err := w.Inspect(&b); err != nil {
+    t.Fatal(err)
+  }
+  text := b.String()
+  for _, s := range []string{ "<dead ent> " + freed.String(), "grandchild", "sibling" } {
+    if !strings.Contains(text, s) {
+      t.Errorf("Inspect output lacks %q:\n%s", s, text)
+    }
+  }
+
+  b.Reset()
+  if err := w.InspectJSON(&b); err != nil {
+    t.Fatal(err)
+  }
+  var doc struct {
+    Transforms []inspectTransformJSON `json:"transforms"`
+  }
+  if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+    t.Fatal(err)
+  }
+  if len(doc.Transforms) != 1 || len(doc.Transforms[0].Children) != 2 {
+    t.Fatalf("unexpected transforms %+v", doc.Transforms)
+  }
+  dead := doc.Transforms[0].Children[0]
+  if !dead.Dead || len(dead.Children) != 1 || dead.Children[0].Name != "grandchild" {
+    t.Errorf("freed child %+v", dead)
+  }
+}
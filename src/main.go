@@ -1,8 +1,11 @@
+// +build js,wasm
+
 package main
 
 import (
   "syscall/js"
   "fmt"
+  "strings"
 )
 
 func main() {
@@ -17,13 +20,46 @@ func main() {
 
   // create renderer
   canvasHtmlElement := js.Global().Get("document").Call("querySelector", "canvas")
-  r, err := NewRenderer(canvasHtmlElement, host.windowWidth, host.windowHeight, host.pixelRatio)
+  gl, err := NewGLContext(canvasHtmlElement)
   if err != nil {
     panic(err)
   }
+  r := NewRenderer(gl, host.windowWidth, host.windowHeight, host.pixelRatio)
   r.init()
   r.start()
 
+  // create world and schedule the renderer to run last in each update
+  world := &World{}
+  world.Init()
+  r.AddToWorld(world)
+  host.ConnectInput(&world.Input)
+  r.Movement = createDemoScene(world)
+  createRenderDemo(world, r)
+
+  // update the world on each frame
+  host.events.Listen(EVAnimationFrame, func (_ Event, _ ...uint32) {
+    host.UpdateAnimationStats()
+    gl.ResetStats() // gl.Stats counts the calls of the current (or last) frame
+    world.Update(host.scenetime)
+  })
+
+  // inspect the world from the browser console:
+  //   console.log(inspectWorld())
+  //   JSON.parse(inspectWorld("json"))
+  js.Global().Set("inspectWorld", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+    var b strings.Builder
+    var err error
+    if len(args) > 0 && args[0].String() == "json" {
+      err = world.InspectJSON(&b)
+    } else {
+      err = world.Inspect(&b)
+    }
+    if err != nil {
+      return err.Error()
+    }
+    return b.String()
+  }))
+
   // resize canvas when window size changes
   host.events.Listen(EVWindowResize, func (ev Event, xy ...uint32) {
     logf("window resized %d, %d, %f", host.windowWidth, host.windowHeight, host.pixelRatio)
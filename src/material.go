@@ -0,0 +1,274 @@
+package main
+
+import "sync/atomic"
+
+// BlendMode is the way a material's colors are combined with what's already drawn
+type BlendMode uint8
+const (
+  BlendNone          = BlendMode(iota) // opaque; replaces the destination
+  BlendAlpha                           // src × src.a + dst × (1 - src.a)
+  BlendAdditive                        // src × src.a + dst
+  BlendPremultiplied                   // src + dst × (1 - src.a), for premultiplied alpha
+)
+
+func (b BlendMode) String() string {
+  switch b {
+  case BlendNone:          return "none"
+  case BlendAlpha:         return "alpha"
+  case BlendAdditive:      return "additive"
+  case BlendPremultiplied: return "premultiplied"
+  }
+  return "(BlendMode?)"
+}
+
+func (b BlendMode) MarshalText() ([]byte, error) {
+  return []byte(b.String()), nil
+}
+
+func (b *BlendMode) UnmarshalText(text []byte) error {
+  for v := BlendNone; v <= BlendPremultiplied; v++ {
+    if v.String() == string(text) {
+      *b = v
+      return nil
+    }
+  }
+  return errorf("invalid blend mode %q", text)
+}
+
+// factors returns the arguments for blendFunc
+func (b BlendMode) factors() (sfactor, dfactor uint32) {
+  switch b {
+  case BlendAlpha:         return GL_SRC_ALPHA, GL_ONE_MINUS_SRC_ALPHA
+  case BlendAdditive:      return GL_SRC_ALPHA, GL_ONE
+  case BlendPremultiplied: return GL_ONE, GL_ONE_MINUS_SRC_ALPHA
+  }
+  return GL_ONE, GL_ZERO
+}
+
+// CullMode selects the faces of triangles that are not drawn. Front faces are those
+// with counter-clockwise vertices on screen.
+type CullMode uint8
+const (
+  CullNone  = CullMode(iota) // draw all triangles
+  CullBack                   // don't draw back faces
+  CullFront                  // don't draw front faces
+)
+
+func (c CullMode) String() string {
+  switch c {
+  case CullNone:  return "none"
+  case CullBack:  return "back"
+  case CullFront: return "front"
+  }
+  return "(CullMode?)"
+}
+
+func (c CullMode) MarshalText() ([]byte, error) {
+  return []byte(c.String()), nil
+}
+
+func (c *CullMode) UnmarshalText(text []byte) error {
+  for v := CullNone; v <= CullFront; v++ {
+    if v.String() == string(text) {
+      *c = v
+      return nil
+    }
+  }
+  return errorf("invalid cull mode %q", text)
+}
+
+// RenderState is the fixed-function GL state that a material is drawn with
+type RenderState struct {
+  Blend      BlendMode
+  Cull       CullMode
+  DepthWrite bool // write to the depth buffer
+}
+
+// DefaultRenderState is the render state of new materials: opaque, no culling and
+// with depth writes
+var DefaultRenderState = RenderState{ DepthWrite: true }
+
+// apply sets the state of dev to s. prev is the state known to be set on dev, or nil
+// if unknown, in which case all state is set.
+func (s *RenderState) apply(dev GraphicsDevice, prev *RenderState) {
+  if prev == nil || s.Blend != prev.Blend {
+    if s.Blend == BlendNone {
+      dev.disable(GL_BLEND)
+    } else {
+      dev.enable(GL_BLEND)
+      dev.blendFunc(s.Blend.factors())
+    }
+  }
+  if prev == nil || s.Cull != prev.Cull {
+    switch s.Cull {
+    case CullNone:
+      dev.disable(GL_CULL_FACE)
+    case CullBack:
+      dev.enable(GL_CULL_FACE)
+      dev.cullFace(GL_BACK)
+    case CullFront:
+      dev.enable(GL_CULL_FACE)
+      dev.cullFace(GL_FRONT)
+    }
+  }
+  if prev == nil || s.DepthWrite != prev.DepthWrite {
+    dev.depthMask(s.DepthWrite)
+  }
+}
+
+// -----------------------------------------------------------------------------
+
+// Material describes how a surface is drawn: with a program, values for the program's
+// uniforms ("parameters") and render state. Materials are shared by drawables and
+// applied by the Renderer, which sorts draws by program and material to minimize state
+// changes (see Renderer.useMaterial.)
+//
+// Parameters are floats, vectors (vec2-vec4), 4x4 matrices and textures. Parameters
+// that the program doesn't declare are ignored. Uniforms that the renderer sets itself
+// (see programState) should not be parameters.
+//
+// Materials can be loaded from files with MaterialLoader.
+type Material struct {
+  Name    string
+  Program *GLProgram
+  State   RenderState
+
+  id      uint32          // unique, for sorting
+  params  []materialParam // in the order they were first set
+  version uint64          // incremented when params change
+}
+
+type materialParamKind uint8
+const (
+  materialFloat   = materialParamKind(iota) // float, vec2, vec3 or vec4
+  materialMatrix4                           // mat4
+  materialTexture                           // sampler2D
+)
+
+type materialParam struct {
+  name     string
+  kind     materialParamKind
+  values   []float32 // float and matrix values
+  texture  *Texture
+  location GLUniform
+  resolved bool // true when location has been looked up
+  declared bool // true if the program declares the uniform
+}
+
+var materialNextID uint32 = 0
+
+// NewMaterial returns a material that draws with program, DefaultRenderState and no
+// parameters
+func NewMaterial(program *GLProgram) *Material {
+  return &Material{
+    Program: program,
+    State:   DefaultRenderState,
+    id:      atomic.AddUint32(&materialNextID, 1),
+  }
+}
+
+// SetFloat sets a float (1 value) or vector (2-4 values) parameter
+func (m *Material) SetFloat(name string, v ...float32) {
+  if len(v) < 1 || len(v) > 4 {
+    panicf("Material.SetFloat: invalid value count %d for %q", len(v), name)
+  }
+  p := m.param(name, materialFloat)
+  p.values = append(p.values[:0], v...)
+}
+
+// SetMatrix4 sets a 4x4 matrix parameter
+func (m *Material) SetMatrix4(name string, v Matrix4) {
+  p := m.param(name, materialMatrix4)
+  p.values = append(p.values[:0], v[:]...)
+}
+
+// SetTexture sets a texture (sampler2D) parameter
+func (m *Material) SetTexture(name string, t *Texture) {
+  m.param(name, materialTexture).texture = t
+}
+
+// Float returns the values of a float or vector parameter, or nil if there's none
+func (m *Material) Float(name string) []float32 {
+  for i := range m.params {
+    if p := &m.params[i]; p.name == name && p.kind == materialFloat {
+      return p.values
+    }
+  }
+  return nil
+}
+
+// Texture returns the texture of a texture parameter, or nil if there's none
+func (m *Material) Texture(name string) *Texture {
+  for i := range m.params {
+    if p := &m.params[i]; p.name == name && p.kind == materialTexture {
+      return p.texture
+    }
+  }
+  return nil
+}
+
+// param returns the parameter called name, adding it if needed, and marks the
+// parameters as changed
+func (m *Material) param(name string, kind materialParamKind) *materialParam {
+  m.version++
+  for i := range m.params {
+    if p := &m.params[i]; p.name == name {
+      if p.kind != kind {
+        *p = materialParam{ name: name, kind: kind }
+      }
+      return p
+    }
+  }
+  m.params = append(m.params, materialParam{ name: name, kind: kind })
+  return &m.params[len(m.params) - 1]
+}
+
+// setUniforms sets all parameters on the program, which must be in use.
+// Texture parameters are assigned texture units in order, starting at 0.
+func (m *Material) setUniforms() {
+  dev := m.Program.dev
+  unit := int32(0)
+  for i := range m.params {
+    p := &m.params[i]
+    if !p.resolved {
+      var err error
+      p.location, err = m.Program.getUniformLocation(p.name)
+      p.declared = err == nil
+      p.resolved = true
+    }
+    switch p.kind {
+    case materialFloat:
+      if p.declared {
+        dev.uniformf(p.location, p.values...)
+      }
+    case materialMatrix4:
+      if p.declared {
+        var v [16]float32
+        copy(v[:], p.values)
+        dev.uniformMatrix4fv(p.location, false, v)
+      }
+    case materialTexture:
+      if p.declared {
+        dev.uniformi(p.location, unit)
+      }
+      unit++
+    }
+  }
+}
+
+// bindTextures binds the material's textures to their texture units (see setUniforms)
+func (m *Material) bindTextures() {
+  dev := m.Program.dev
+  unit := uint32(0)
+  for i := range m.params {
+    if p := &m.params[i]; p.kind == materialTexture {
+      dev.activeTexture(GL_TEXTURE0 + unit)
+      if p.texture != nil {
+        dev.bindTexture(GL_TEXTURE_2D, p.texture.tex)
+      } else {
+        dev.bindTexture(GL_TEXTURE_2D, GLTexture{})
+      }
+      unit++
+    }
+  }
+}
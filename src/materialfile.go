@@ -0,0 +1,199 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "image"
+  _ "image/jpeg"
+  _ "image/png"
+  "io"
+  "io/ioutil"
+  "path"
+  "sort"
+)
+
+// MaterialLoader loads materials from JSON files. Shaders and images are read with
+// Open, relative to the material file, and are shared by all materials loaded by the
+// same loader. Example of a material file:
+//
+//   {
+//     "name": "glass",
+//     "vertexShader": "shaders/basic.vert",
+//     "fragmentShader": "shaders/tinted.frag",
+//     "blend": "alpha",
+//     "cull": "back",
+//     "depthWrite": false,
+//     "params": {
+//       "uOpacity": 0.5,
+//       "uTint": [0.8, 0.9, 1.0],
+//       "uUVTransform": [1,0,0,0, 0,1,0,0, 0,0,1,0, 0,0,0,1],
+//       "uTexture": "images/glass.png"
+//     }
+//   }
+//
+// Fields:
+//
+//   blend       none (default), alpha, additive or premultiplied
+//   cull        none (default), back or front
+//   depthWrite  default true
+//   params      uniform values by name: a number for a float, an array of 2-4 numbers
+//               for a vec2-vec4, 16 numbers for a mat4 (column-major), or the name of
+//               a PNG or JPEG image for a sampler2D
+//
+// Texture parameters are assigned texture units in name order.
+type MaterialLoader struct {
+  Dev  GraphicsDevice
+  Open func(name string) (io.ReadCloser, error)
+
+  programs map[[2]string]*GLProgram // keyed by vertex and fragment shader names
+  textures map[string]*Texture      // keyed by image name
+}
+
+// NewMaterialLoader returns a loader that creates programs and textures on dev and
+// reads files with open, e.g. a function calling os.Open
+func NewMaterialLoader(dev GraphicsDevice, open func(name string) (io.ReadCloser, error)) *MaterialLoader {
+  return &MaterialLoader{ Dev: dev, Open: open }
+}
+
+type materialJSON struct {
+  Name           string                     `json:"name"`
+  VertexShader   string                     `json:"vertexShader"`
+  FragmentShader string                     `json:"fragmentShader"`
+  Blend          BlendMode                  `json:"blend"`
+  Cull           CullMode                   `json:"cull"`
+  DepthWrite     *bool                      `json:"depthWrite"`
+  Params         map[string]json.RawMessage `json:"params"`
+}
+
+// Load reads the material file called name
+func (l *MaterialLoader) Load(name string) (*Material, error) {
+  data, err := l.readFile(name)
+  if err != nil {
+    return nil, err
+  }
+  var doc materialJSON
+  if err := json.Unmarshal(data, &doc); err != nil {
+    return nil, errorf("%s: %v", name, err)
+  }
+  if doc.VertexShader == "" || doc.FragmentShader == "" {
+    return nil, errorf("%s: missing vertexShader or fragmentShader", name)
+  }
+  dir := path.Dir(name)
+  program, err := l.program(path.Join(dir, doc.VertexShader), path.Join(dir, doc.FragmentShader))
+  if err != nil {
+    return nil, errorf("%s: %v", name, err)
+  }
+
+  m := NewMaterial(program)
+  m.Name = doc.Name
+  m.State.Blend = doc.Blend
+  m.State.Cull = doc.Cull
+  if doc.DepthWrite != nil {
+    m.State.DepthWrite = *doc.DepthWrite
+  }
+
+  // sorted for texture units that don't depend on map order
+  names := make([]string, 0, len(doc.Params))
+  for pname := range doc.Params {
+    names = append(names, pname)
+  }
+  sort.Strings(names)
+  for _, pname := range names {
+    if err := l.loadParam(m, pname, doc.Params[pname], dir); err != nil {
+      return nil, errorf("%s: param %q: %v", name, pname, err)
+    }
+  }
+  return m, nil
+}
+
+func (l *MaterialLoader) loadParam(m *Material, name string, data json.RawMessage, dir string) error {
+  if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+    // would otherwise decode as 0 or as an empty image name
+    return errorf("null is not a valid value")
+  }
+  var f float32
+  if json.Unmarshal(data, &f) == nil {
+    m.SetFloat(name, f)
+    return nil
+  }
+  var imageName string
+  if json.Unmarshal(data, &imageName) == nil {
+    t, err := l.texture(path.Join(dir, imageName))
+    if err != nil {
+      return err
+    }
+    m.SetTexture(name, t)
+    return nil
+  }
+  var v []float32
+  if err := json.Unmarshal(data, &v); err != nil {
+    return errorf("expected a number, an array of numbers or an image name")
+  }
+  switch len(v) {
+  case 1, 2, 3, 4:
+    m.SetFloat(name, v...)
+  case 16:
+    var mat Matrix4
+    copy(mat[:], v)
+    m.SetMatrix4(name, mat)
+  default:
+    return errorf("invalid value count %d (expected 1-4 or 16)", len(v))
+  }
+  return nil
+}
+
+// program returns the program linked from the named shaders, creating it if needed
+func (l *MaterialLoader) program(vname, fname string) (*GLProgram, error) {
+  key := [2]string{ vname, fname }
+  if p := l.programs[key]; p != nil {
+    return p, nil
+  }
+  vsource, err := l.readFile(vname)
+  if err != nil {
+    return nil, err
+  }
+  fsource, err := l.readFile(fname)
+  if err != nil {
+    return nil, err
+  }
+  p, err := NewGLProgramSource(l.Dev, string(vsource), string(fsource))
+  if err != nil {
+    return nil, err
+  }
+  if l.programs == nil {
+    l.programs = make(map[[2]string]*GLProgram)
+  }
+  l.programs[key] = p
+  return p, nil
+}
+
+// texture returns a texture with the named image, creating it if needed
+func (l *MaterialLoader) texture(name string) (*Texture, error) {
+  if t := l.textures[name]; t != nil {
+    return t, nil
+  }
+  r, err := l.Open(name)
+  if err != nil {
+    return nil, err
+  }
+  defer r.Close()
+  img, _, err := image.Decode(r)
+  if err != nil {
+    return nil, errorf("%s: %v", name, err)
+  }
+  t := NewTexture(l.Dev, img, GL_CLAMP_TO_EDGE)
+  if l.textures == nil {
+    l.textures = make(map[string]*Texture)
+  }
+  l.textures[name] = t
+  return t, nil
+}
+
+func (l *MaterialLoader) readFile(name string) ([]byte, error) {
+  r, err := l.Open(name)
+  if err != nil {
+    return nil, err
+  }
+  defer r.Close()
+  return ioutil.ReadAll(r)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+  "bytes"
+  "image"
+  "image/png"
+  "io"
+  "io/ioutil"
+  "os"
+  "strings"
+  "testing"
+)
+
+const materialTestVertexShader = `
+attribute vec4 aVertexPosition;
+uniform mat4 uModelViewMatrix;
+uniform mat4 uProjectionMatrix;
+uniform mat4 uUVTransform;
+void main() {
+  gl_Position = uProjectionMatrix * uModelViewMatrix * aVertexPosition;
+}
+`
+
+const materialTestFragmentShader = `
+precision mediump float;
+uniform float uOpacity;
+uniform vec3 uTint;
+uniform sampler2D uTexture;
+void main() {
+  gl_FragColor = vec4(uTint, uOpacity);
+}
+`
+
+// the example of the MaterialLoader documentation
+const materialTestGlass = `{
+  "name": "glass",
+  "vertexShader": "shaders/basic.vert",
+  "fragmentShader": "shaders/tinted.frag",
+  "blend": "alpha",
+  "cull": "back",
+  "depthWrite": false,
+  "params": {
+    "uOpacity": 0.5,
+    "uTint": [0.8, 0.9, 1.0],
+    "uUVTransform": [1,0,0,0, 0,1,0,0, 0,0,1,0, 0,0,0,1],
+    "uTexture": "images/glass.png"
+  }
+}`
+
+// newTestMaterialLoader returns a loader on dev which reads files from memory
+func newTestMaterialLoader(t *testing.T, dev GraphicsDevice) *MaterialLoader {
+  var img bytes.Buffer
+  if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+    t.Fatal(err)
+  }
+  files := map[string]string{
+    "shaders/basic.vert":  materialTestVertexShader,
+    "shaders/tinted.frag": materialTestFragmentShader,
+    "images/glass.png":    img.String(),
+    "glass.json":          materialTestGlass,
+    "frosted.json": `{
+      "vertexShader": "shaders/basic.vert",
+      "fragmentShader": "shaders/tinted.frag",
+      "params": { "uOpacity": 0.8, "uTexture": "images/glass.png" }
+    }`,
+    "null.json": `{
+      "vertexShader": "shaders/basic.vert",
+      "fragmentShader": "shaders/tinted.frag",
+      "params": { "uOpacity": null }
+    }`,
+  }
+  return NewMaterialLoader(dev, func(name string) (io.ReadCloser, error) {
+    data, ok := files[name]
+    if !ok {
+      return nil, os.ErrNotExist
+    }
+    return ioutil.NopCloser(strings.NewReader(data)), nil
+  })
+}
+
+func TestMaterialLoaderShares(t *testing.T) {
+  dev := NewRecordingDevice()
+  l := newTestMaterialLoader(t, dev)
+  glass, err := l.Load("glass.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+  frosted, err := l.Load("frosted.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if glass.State.Blend != BlendAlpha || glass.State.Cull != CullBack || glass.State.DepthWrite {
+    t.Errorf("glass render state %+v", glass.State)
+  }
+  if glass.Program != frosted.Program || len(dev.Programs) != 1 {
+    t.Errorf("program not shared; %d programs", len(dev.Programs))
+  }
+  if glass.Texture("uTexture") == nil || glass.Texture("uTexture") != frosted.Texture("uTexture") ||
+     len(dev.Textures) != 1 {
+    t.Errorf("texture not shared; %d textures", len(dev.Textures))
+  }
+  if _, err := l.Load("null.json"); err == nil {
+    t.Error("no error for null param")
+  }
+}
+
+func TestMaterialUniformsOnlySetWhenChanged(t *testing.T) {
+  w := newTestWorld()
+  dev := NewRecordingDevice()
+  r := NewRenderer(dev, 100, 100, 1)
+  r.AddToWorld(w)
+  glass, err := newTestMaterialLoader(t, dev).Load("glass.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+  cube, err := NewGLCube(glass)
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i := 0; i < 2; i++ {
+    e := w.Ents.Alloc()
+    w.CreateNode(e, Matrix4Identity)
+    r.Drawables.Assoc(e, cube)
+  }
+
+  params := func() []string {
+    var cmds []string
+    for _, c := range programCommands(dev) {
+      if strings.HasSuffix(c, " uModelViewMatrix") || strings.HasSuffix(c, " uProjectionMatrix") {
+        continue // set by the renderer
+      }
+      cmds = append(cmds, c)
+    }
+    return cmds
+  }
+  want := []string{ // parameters in name order, as loaded
+    "UseProgram",
+    "Uniformf uOpacity",
+    "Uniformi uTexture",
+    "Uniformf uTint",
+    "UniformMatrix uUVTransform",
+    "DrawElements",
+    "DrawElements",
+  }
+  for frame, want := range [][]string{
+    want,
+    { "UseProgram", "DrawElements", "DrawElements" }, // unchanged; no parameters are set
+    want,                                           // changed
+  } {
+    if frame == 2 {
+      glass.SetFloat("uOpacity", 0.25)
+    }
+    dev.Reset()
+    w.Update(float64(frame))
+    if got := params(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+      t.Errorf("frame %d: commands:\n  %s\nexpected:\n  %s",
+        frame, strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+    }
+  }
+}
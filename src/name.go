@@ -0,0 +1,252 @@
+package main
+
+import "strings"
+
+// NameSystem gives entities optional names, which are used for lookup (see Find and
+// FindPath), in scene files and when debugging.
+//
+// Names don't need to be unique, but a name can not contain "/" since that is used
+// to separate names in paths. Path lookup follows the TransformSystem hierarchy, so
+// "player/gun" is an entity named "gun" which is a child of a root entity named
+// "player".
+type NameSystem struct {
+  ComponentStore
+  world *World
+  data  stringArray
+}
+
+func (s *NameSystem) Init(world *World) {
+  s.world = world
+  s.ComponentStore.Init(&world.Ents, &s.data)
+}
+
+// Get returns the name of ent, or "" if ent has no name
+func (s *NameSystem) Get(ent Ent) string {
+  if DEBUG {
+    checkAlive(s.em, ent, 0)
+  }
+  if i := s.Index(ent); i != -1 {
+    return s.data[i]
+  }
+  return ""
+}
+
+// SetName sets the name of ent. An empty name removes the name.
+func (s *NameSystem) SetName(ent Ent, name string) {
+  if strings.IndexByte(name, '/') != -1 {
+    panicf("NameSystem.SetName: name %q contains \"/\"", name)
+  }
+  if name == "" {
+    s.Remove(ent)
+  } else {
+    s.data[s.Add(ent)] = name
+  }
+}
+
+func (s *NameSystem) DestroyEnt(ent Ent) {
+  s.Remove(ent)
+}
+
+// Find returns the first entity named name, or NilEnt if there's no such entity
+func (s *NameSystem) Find(name string) Ent {
+  for i, v := range s.data {
+    if v == name {
+      return s.ents[i]
+    }
+  }
+  return NilEnt
+}
+
+// FindPath returns the entity at path, e.g. "player/gun", where the first name is
+// that of a root entity. Returns NilEnt if there's no such entity.
+func (s *NameSystem) FindPath(path string) Ent {
+  return s.FindChild(NilEnt, path)
+}
+
+// FindChild returns the descendant of parent at path, e.g. "gun/barrel".
+// If parent is NilEnt, path is relative to the root entities.
+// When several siblings share a name, the first one that leads to a match is used.
+func (s *NameSystem) FindChild(parent Ent, path string) Ent {
+  name := path
+  rest := ""
+  if i := strings.IndexByte(path, '/'); i != -1 {
+    name, rest = path[:i], path[i+1:]
+  }
+  for i, v := range s.data {
+    if v != name {
+      continue
+    }
+    e := s.ents[i]
+    if !s.world.Ents.IsAlive(e) {
+      continue
+    }
+    p := NilEnt // entities without a transform node are roots
+    if n := s.world.TransformSystem.Get(e); n != nil {
+      p = n.parent
+    }
+    if p != parent {
+      continue
+    }
+    if rest == "" {
+      return e
+    }
+    if found := s.FindChild(e, rest); found != NilEnt {
+      return found
+    }
+  }
+  return NilEnt
+}
+
+// Path returns the path of ent, e.g. "player/gun". Unnamed entities, and ancestors
+// that were freed without World.Destroy, are represented by their Ent string, e.g.
+// "player/Ent#5".
+func (s *NameSystem) Path(ent Ent) string {
+  var names []string
+  for e := ent; e != NilEnt; {
+    name := ""
+    if s.em.IsAlive(e) {
+      name = s.Get(e)
+    }
+    if name == "" {
+      name = e.String()
+    }
+    names = append(names, name)
+    n := s.world.TransformSystem.node(e)
+    if n == nil {
+      break
+    }
+    e = n.parent
+  }
+  for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+    names[i], names[j] = names[j], names[i]
+  }
+  return strings.Join(names, "/")
+}
+
+
+type stringArray []string
+
+func (a *stringArray) Append()               { *a = append(*a, "") }
+func (a *stringArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *stringArray) Truncate(n int)        { *a = (*a)[:n] }
+func (a *stringArray) Ptr(i int) interface{} { return &(*a)[i] }
+
+// -----------------------------------------------------------------------------
+
+// TagSet is a set of tags of a TagSystem, one bit per tag
+type TagSet uint64
+
+// MaxTags is the maximum number of distinct tags of a TagSystem
+const MaxTags = 64
+
+// TagSystem attaches sets of tags, like "enemy" or "pickup", to entities.
+// Use Query.Tagged to iterate over entities with certain tags.
+type TagSystem struct {
+  ComponentStore
+  data  tagSetArray
+  names []string // tag names; bit i of a TagSet is names[i]
+}
+
+func (s *TagSystem) Init(world *World) {
+  s.ComponentStore.Init(&world.Ents, &s.data)
+  s.names = s.names[:0]
+}
+
+// Tag returns the TagSet of a single tag, adding the tag if it's new
+func (s *TagSystem) Tag(name string) TagSet {
+  for i, v := range s.names {
+    if v == name {
+      return TagSet(1) << uint(i)
+    }
+  }
+  if len(s.names) == MaxTags {
+    panicf("TagSystem.Tag: too many tags (max %d)", MaxTags)
+  }
+  s.names = append(s.names, name)
+  return TagSet(1) << uint(len(s.names) - 1)
+}
+
+// TagSet returns the TagSet of tags, adding any new tags
+func (s *TagSystem) TagSet(tags ...string) TagSet {
+  var set TagSet
+  for _, name := range tags {
+    set |= s.Tag(name)
+  }
+  return set
+}
+
+// Lookup returns the TagSet of tags without adding new tags. ok is false if some of
+// tags are unknown; set then holds the known ones.
+func (s *TagSystem) Lookup(tags ...string) (set TagSet, ok bool) {
+  ok = true
+  outer:
+  for _, name := range tags {
+    for i, v := range s.names {
+      if v == name {
+        set |= TagSet(1) << uint(i)
+        continue outer
+      }
+    }
+    ok = false
+  }
+  return set, ok
+}
+
+// Names returns the names of the tags in set
+func (s *TagSystem) Names(set TagSet) []string {
+  var names []string
+  for i, name := range s.names {
+    if set & (TagSet(1) << uint(i)) != 0 {
+      names = append(names, name)
+    }
+  }
+  return names
+}
+
+// Get returns the tags of ent
+func (s *TagSystem) Get(ent Ent) TagSet {
+  if DEBUG {
+    checkAlive(s.em, ent, 0)
+  }
+  if i := s.Index(ent); i != -1 {
+    return s.data[i]
+  }
+  return 0
+}
+
+// Has returns true if ent has all of tags
+func (s *TagSystem) Has(ent Ent, tags ...string) bool {
+  set, ok := s.Lookup(tags...)
+  return ok && s.Get(ent) & set == set
+}
+
+// AddTags adds tags to ent
+func (s *TagSystem) AddTags(ent Ent, tags ...string) {
+  set := s.TagSet(tags...)
+  s.data[s.ComponentStore.Add(ent)] |= set
+}
+
+// RemoveTags removes tags from ent. Entities without tags are removed from the store.
+func (s *TagSystem) RemoveTags(ent Ent, tags ...string) {
+  i := s.Index(ent)
+  if i == -1 {
+    return
+  }
+  set, _ := s.Lookup(tags...)
+  s.data[i] &^= set
+  if s.data[i] == 0 {
+    s.ComponentStore.Remove(ent)
+  }
+}
+
+func (s *TagSystem) DestroyEnt(ent Ent) {
+  s.ComponentStore.Remove(ent)
+}
+
+
+type tagSetArray []TagSet
+
+func (a *tagSetArray) Append()               { *a = append(*a, 0) }
+func (a *tagSetArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *tagSetArray) Truncate(n int)        { *a = (*a)[:n] }
+func (a *tagSetArray) Ptr(i int) interface{} { return &(*a)[i] }
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// Looking up tags must not add them, or a world could run out of tags by querying
+func TestTagLookupDoesNotAddTags(t *testing.T) {
+  w, movement := newSceneTestWorld()
+  monster := w.Ents.Alloc()
+  movement.Assoc(monster, MovementData{})
+  w.Tags.AddTags(monster, "enemy")
+
+  if w.Tags.Has(monster, "enemy", "boss") {
+    t.Error("Has matched an unknown tag")
+  }
+  q := w.Query(&movement.ComponentStore).Tagged("boss")
+  for q.Next() {
+    t.Error("Query.Tagged matched an unknown tag")
+  }
+  w.Tags.RemoveTags(monster, "boss")
+  if len(w.Tags.names) != 1 {
+    t.Fatalf("tags %v; expected only enemy", w.Tags.names)
+  }
+
+  // tags added after the query was created are matched by the next iteration
+  w.Tags.AddTags(monster, "boss")
+  n := 0
+  for q.Reset(); q.Next(); {
+    n++
+  }
+  if n != 1 {
+    t.Errorf("Query.Tagged matched %d entities after the tag was added; expected 1", n)
+  }
+}
@@ -0,0 +1,122 @@
+package main
+
+import "io"
+
+// Prefab is a template for a group of entities, usually a transform subtree, which can
+// be instantiated any number of times with World.Instantiate.
+//
+// Prefabs can be built in Go:
+//
+//   p := &Prefab{}
+//   player := p.Add(-1, "player", NewSceneTransform(Matrix4Identity))
+//   p.Set(player, "movement", MovementData{ mass: 1.0 })
+//   p.Add(player, "gun", NewSceneTransform(Matrix4Identity))
+//
+// or read from a scene file with World.LoadPrefab, or captured from existing entities
+// with World.CapturePrefab.
+//
+type Prefab struct {
+  Scene
+}
+
+// PrefabOverride changes one entity of a prefab instance.
+// If Transform is non-nil it replaces the entity's transform.
+// If Component is non-empty, Value replaces or adds the component's value.
+type PrefabOverride struct {
+  Entity    int             // index of the entity in the prefab, e.g. from Prefab.Index
+  Component string          // name of a registered component
+  Value     interface{}     // component value, e.g. MovementData
+  Transform *SceneTransform // replacement transform
+}
+
+// NewSceneTransform returns a SceneTransform decomposed from local
+func NewSceneTransform(local Matrix4) *SceneTransform {
+  t := &SceneTransform{}
+  t.Position, t.Rotation, t.Scale = local.Decompose()
+  return t
+}
+
+// Add adds an entity to the prefab and returns its index.
+// parent is the index of the parent entity, or -1 for a root entity.
+// name is optional and becomes the name of instances of the entity (see World.Names.)
+// It can also be used with Index to find the entity, e.g. for overrides.
+// transform is nil for entities without a transform.
+func (p *Prefab) Add(parent int, name string, transform *SceneTransform) int {
+  i := len(p.Entities)
+  if parent >= i || parent < -1 {
+    panicf("Prefab.Add: invalid parent %d", parent)
+  }
+  p.Entities = append(p.Entities, SceneEntity{
+    Parent:    parent,
+    Name:      name,
+    Transform: transform,
+  })
+  return i
+}
+
+// Set sets the value of a component of the i:th entity
+func (p *Prefab) Set(i int, component string, value interface{}) {
+  se := &p.Entities[i]
+  if se.Components == nil {
+    se.Components = make(map[string]interface{})
+  }
+  se.Components[component] = value
+}
+
+// Index returns the index of the first entity with name, or -1 if not found
+func (p *Prefab) Index(name string) int {
+  for i := range p.Entities {
+    if p.Entities[i].Name == name {
+      return i
+    }
+  }
+  return -1
+}
+
+// LoadPrefab reads a prefab from a scene file in either format
+func (w *World) LoadPrefab(in io.Reader) (*Prefab, error) {
+  s, err := w.ReadScene(in)
+  if err != nil {
+    return nil, err
+  }
+  return &Prefab{ Scene: *s }, nil
+}
+
+// CapturePrefab returns a prefab of root and all of its descendants
+func (w *World) CapturePrefab(root Ent) *Prefab {
+  return &Prefab{ Scene: *w.CaptureScene(root) }
+}
+
+// Instantiate spawns a new copy of the prefab's entities with overrides applied.
+// Root entities with a transform are added as children of parent, unless parent is
+// NilEnt. Returns the new entities in the same order as p.Entities, or an error from
+// SpawnScene, e.g. for an override of an unknown component.
+func (w *World) Instantiate(p *Prefab, parent Ent, overrides ...PrefabOverride) ([]Ent, error) {
+  s := &p.Scene
+  if len(overrides) > 0 {
+    // copy entities that are overridden, leaving p unmodified
+    s = &Scene{ Entities: append([]SceneEntity(nil), p.Entities...) }
+    copied := make(map[int]bool, len(overrides))
+    for _, o := range overrides {
+      if o.Entity < 0 || o.Entity >= len(s.Entities) {
+        panicf("World.Instantiate: invalid override entity %d", o.Entity)
+      }
+      se := &s.Entities[o.Entity]
+      if !copied[o.Entity] {
+        copied[o.Entity] = true
+        components := make(map[string]interface{}, len(se.Components) + 1)
+        for k, v := range se.Components {
+          components[k] = v
+        }
+        se.Components = components
+      }
+      if o.Transform != nil {
+        se.Transform = o.Transform
+      }
+      if o.Component != "" {
+        se.Components[o.Component] = o.Value
+      }
+    }
+  }
+  return w.SpawnScene(s, parent)
+}
@@ -0,0 +1,193 @@
+package main
+
+// Query iterates over entities that have a set of components and, optionally, don't
+// have another set of components, must have certain tags or must have changed
+// components.
+//
+// Example:
+//
+//   q := w.Query(&w.TransformSystem.store, &movement.ComponentStore)
+//   for q.Next() {
+//     node := q.Get(0).(*TransformNode)
+//     data := movement.At(q.Index(1))  // same thing as q.Get(1).(*MovementData)
+//     ...
+//   }
+//
+// Entities are visited in reverse dense order of the smallest of the "with" stores.
+// This makes it safe to remove components from the current entity during iteration,
+// but adding components or removing components from other entities might cause
+// entities to be skipped. Use a command buffer for such changes.
+//
+type Query struct {
+  world    *World
+  with     []*ComponentStore
+  without  []*ComponentStore
+  tagNames []string         // tags that entities must have
+  tags     TagSet           // tagNames looked up in world.Tags
+  noTags   bool             // some of tagNames are unknown, so no entity matches
+  changed  []queryChanged   // stores whose components must have changed
+  lead     *ComponentStore  // the smallest store in with; the one we iterate over
+  index    []int            // index[i] is the current entity's index into with[i]
+  i        int              // current index into lead
+  ent      Ent              // current entity
+}
+
+// Query returns a new query for entities that have a component in all of the with
+// stores. At least one store must be provided.
+func (w *World) Query(with ...*ComponentStore) *Query {
+  if len(with) == 0 {
+    panicf("World.Query: no component stores")
+  }
+  q := &Query{
+    world: w,
+    with:  with,
+    index: make([]int, len(with)),
+  }
+  q.Reset()
+  return q
+}
+
+// Without adds stores of components that entities must not have to be matched.
+// Returns q to allow chaining.
+func (q *Query) Without(without ...*ComponentStore) *Query {
+  q.without = append(q.without, without...)
+  return q
+}
+
+type queryChanged struct {
+  store *ComponentStore
+  since uint32 // store version when the previous iteration started
+  start uint32 // store version when the current iteration started
+}
+
+// Changed limits matches to entities whose components in stores were added or changed
+// since the previous complete iteration of q (see ComponentStore.MarkChanged.)
+// The first iteration matches all entities with the components. A query that is
+// reused every tick thus visits entities that changed since the last tick.
+// Returns q to allow chaining.
+//
+// Example:
+//
+//   q := w.Query(&w.TransformSystem.store).Changed(&w.TransformSystem.store)
+//   // each frame:
+//   for q.Reset(); q.Next(); {
+//     updateSpatialIndex(q.Ent(), q.Get(0).(*TransformNode))
+//   }
+//
+func (q *Query) Changed(stores ...*ComponentStore) *Query {
+  for _, c := range stores {
+    q.changed = append(q.changed, queryChanged{ store: c, start: c.Version() })
+  }
+  return q
+}
+
+// Tagged limits matches to entities that have all of tags (see World.Tags.)
+// Tags are looked up when iteration starts; a tag that no entity has been tagged
+// with yet matches nothing. Returns q to allow chaining.
+//
+// Example:
+//
+//   q := w.Query(&movement.ComponentStore).Tagged("enemy")
+//
+func (q *Query) Tagged(tags ...string) *Query {
+  q.tagNames = append(q.tagNames, tags...)
+  q.lookupTags()
+  return q
+}
+
+// lookupTags updates tags from tagNames without adding new tags to World.Tags
+func (q *Query) lookupTags() {
+  var ok bool
+  q.tags, ok = q.world.Tags.Lookup(q.tagNames...)
+  q.noTags = !ok
+}
+
+// Reset restarts iteration. A query can be reused many times.
+func (q *Query) Reset() {
+  q.lead = q.with[0]
+  for _, c := range q.with[1:] {
+    if c.Len() < q.lead.Len() {
+      q.lead = c
+    }
+  }
+  q.i = q.lead.Len()
+  q.ent = NilEnt
+  if len(q.tagNames) > 0 {
+    q.lookupTags() // tags may have been added since
+  }
+  for i := range q.changed {
+    q.changed[i].start = q.changed[i].store.Version()
+  }
+}
+
+// Next advances to the next matching entity. Returns false when there are no more.
+func (q *Query) Next() bool {
+  outer:
+  for q.i--; q.i >= 0; q.i-- {
+    if q.i >= q.lead.Len() {
+      // components were removed during iteration
+      continue
+    }
+    e := q.lead.EntAt(q.i)
+    if !q.world.Ents.IsAlive(e) {
+      continue
+    }
+    for i, c := range q.with {
+      if q.index[i] = c.Index(e); q.index[i] == -1 {
+        continue outer
+      }
+    }
+    for _, c := range q.without {
+      if c.Has(e) {
+        continue outer
+      }
+    }
+    if q.noTags || q.world.Tags.Get(e) & q.tags != q.tags {
+      continue
+    }
+    for _, c := range q.changed {
+      if !c.store.ChangedSince(e, c.since) {
+        continue outer
+      }
+    }
+    q.ent = e
+    return true
+  }
+  if q.i == -1 {
+    // iteration complete; the next iteration only visits later changes
+    for i := range q.changed {
+      q.changed[i].since = q.changed[i].start
+    }
+  }
+  q.ent = NilEnt
+  return false
+}
+
+// Ent returns the current entity
+func (q *Query) Ent() Ent {
+  return q.ent
+}
+
+// Index returns the current entity's dense index in the i:th "with" store
+func (q *Query) Index(i int) int {
+  return q.index[i]
+}
+
+// Get returns a pointer to the current entity's component in the i:th "with" store.
+// The pointer is only valid until components are added to or removed from the store.
+func (q *Query) Get(i int) interface{} {
+  return q.with[i].data.Ptr(q.index[i])
+}
+
+// Count returns the number of matching entities. Resets iteration.
+// Unlike a complete iteration, Count does not affect what Changed matches.
+func (q *Query) Count() int {
+  changed := append([]queryChanged(nil), q.changed...)
+  n := 0
+  for q.Reset(); q.Next(); {
+    n++
+  }
+  copy(q.changed, changed)
+  q.Reset()
+  return n
+}
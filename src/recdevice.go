@@ -0,0 +1,394 @@
+package main
+
+import (
+  "strconv"
+  "strings"
+)
+
+// RecordingDevice is a headless GraphicsDevice that records commands and resources
+// instead of drawing anything. It works anywhere Go runs, which makes it possible to
+// test the renderer and drawables without a browser.
+//
+// Example:
+//
+//   dev := NewRecordingDevice()
+//   r := NewRenderer(dev, 320, 240, 1)
+//   r.AddToWorld(w)
+//   ...
+//   dev.Reset()
+//   r.render(0)
+//   for _, c := range dev.Draws() {
+//     fmt.Println(c) // e.g. "DrawElements(4 36 5123 0) program 3"
+//   }
+//
+type RecordingDevice struct {
+  Width, Height uint32 // drawing buffer size in pixels (see setCanvasSize)
+  Buffers  map[uintptr]*RecordedBuffer  // keyed by GLBuffer.id
+  Textures map[uintptr]*RecordedTexture // keyed by GLTexture.id
+  Programs map[uintptr]*RecordedProgram // keyed by GLProgram.id
+  Uniforms map[GLUniform]RecordedUniform
+  Flushes  int // number of calls to flush
+
+  cmd           GLCommandBuffer    // commands recorded since Reset
+  arrayBuffer   uintptr            // buffer bound to GL_ARRAY_BUFFER
+  elementBuffer uintptr            // buffer bound to GL_ELEMENT_ARRAY_BUFFER
+  activeTex     uint32             // e.g. GL_TEXTURE0
+  textures      map[uint32]uintptr // texture bound to GL_TEXTURE_2D, by texture unit
+}
+
+// RecordedBuffer is a buffer created on a RecordingDevice.
+// F32 or U16 holds the data last passed to bufferDataF32 or bufferDataU16.
+type RecordedBuffer struct {
+  Target uint32 // target the buffer was bound to when data was last set
+  Usage  uint32
+  F32    []float32
+  U16    []uint16
+}
+
+// RecordedTexture is a texture created on a RecordingDevice.
+// Pixels holds the RGBA data last passed to texImage2D.
+type RecordedTexture struct {
+  Width, Height uint32
+  Pixels        []uint8
+  Params        map[uint32]int32 // texParameteri values by pname
+}
+
+// RecordedProgram is a program created on a RecordingDevice
+type RecordedProgram struct {
+  VertexSource   string
+  FragmentSource string
+  Attribs        map[string]uint32 // attribute locations, by name
+  uniforms       map[string]bool   // declared uniforms
+}
+
+// RecordedUniform describes a uniform location returned by a RecordingDevice
+type RecordedUniform struct {
+  Program uintptr // GLProgram.id
+  Name    string
+}
+
+// RecordedCommand is a command recorded by a RecordingDevice.
+// Args are encoded as by GLCommandBuffer; use Float to read float arguments.
+type RecordedCommand struct {
+  Op      GLOp
+  Args    []uint32
+  Program uintptr // id of the program in use when the command was recorded
+}
+
+func (c RecordedCommand) Float(i int) float32 {
+  return GLArgFloat(c.Args[i])
+}
+
+// UniformMatrix4 returns the matrix of a recorded 4x4 GLOpUniformMatrix command
+func (c RecordedCommand) UniformMatrix4() (m Matrix4) {
+  if c.Op != GLOpUniformMatrix || len(c.Args) != 2 + 16 {
+    panicf("RecordedCommand.UniformMatrix4: not a 4x4 matrix command: %s", c)
+  }
+  for i := range m {
+    m[i] = c.Float(2 + i)
+  }
+  return
+}
+
+func (c RecordedCommand) String() string {
+  var b strings.Builder
+  b.WriteString(c.Op.String())
+  b.WriteByte('(')
+  for i, v := range c.Args {
+    if i > 0 {
+      b.WriteByte(' ')
+    }
+    b.WriteString(strconv.FormatUint(uint64(v), 10))
+  }
+  b.WriteByte(')')
+  if c.Program != 0 {
+    b.WriteString(" program ")
+    b.WriteString(strconv.FormatUint(uint64(c.Program), 10))
+  }
+  return b.String()
+}
+
+func NewRecordingDevice() *RecordingDevice {
+  return &RecordingDevice{
+    Buffers:   make(map[uintptr]*RecordedBuffer),
+    Textures:  make(map[uintptr]*RecordedTexture),
+    Programs:  make(map[uintptr]*RecordedProgram),
+    Uniforms:  make(map[GLUniform]RecordedUniform),
+    activeTex: GL_TEXTURE0,
+    textures:  make(map[uint32]uintptr),
+  }
+}
+
+// Reset forgets recorded commands. Resources are kept.
+func (d *RecordingDevice) Reset() {
+  d.cmd.Reset()
+}
+
+// Commands returns the commands recorded since the last call to Reset
+func (d *RecordingDevice) Commands() []RecordedCommand {
+  var cmds []RecordedCommand
+  var program uintptr
+  err := DecodeGLCommands(d.cmd.Words(), func(op GLOp, args []uint32) {
+    if op == GLOpUseProgram {
+      program = uintptr(args[0])
+    }
+    cmds = append(cmds, RecordedCommand{
+      Op:      op,
+      Args:    append([]uint32(nil), args...),
+      Program: program,
+    })
+  })
+  if err != nil {
+    panic(err) // the buffer is encoded by d, so this is a bug
+  }
+  return cmds
+}
+
+// Draws returns the drawArrays and drawElements commands recorded since the last call
+// to Reset
+func (d *RecordingDevice) Draws() []RecordedCommand {
+  var draws []RecordedCommand
+  for _, c := range d.Commands() {
+    if c.Op == GLOpDrawArrays || c.Op == GLOpDrawElements {
+      draws = append(draws, c)
+    }
+  }
+  return draws
+}
+
+// --------------------------------------------------------------------------------------
+// GraphicsDevice implementation
+
+func (d *RecordingDevice) drawingBufferSize() (width, height uint32) {
+  return d.Width, d.Height
+}
+
+func (d *RecordingDevice) setCanvasSize(width, height uint32, pixelRatio float32) {
+  d.Width  = uint32(float32(width) * pixelRatio)
+  d.Height = uint32(float32(height) * pixelRatio)
+}
+
+func (d *RecordingDevice) viewport(x, y int32, width, height uint32) {
+  d.cmd.Viewport(x, y, int32(width), int32(height))
+}
+
+func (d *RecordingDevice) clear(mask uint32)             { d.cmd.Clear(mask) }
+func (d *RecordingDevice) clearColor(r, g, b, a float32) { d.cmd.ClearColor(r, g, b, a) }
+func (d *RecordingDevice) clearDepth(depth float32)      { d.cmd.ClearDepth(depth) }
+func (d *RecordingDevice) enable(cap uint32)             { d.cmd.Enable(cap) }
+func (d *RecordingDevice) disable(cap uint32)            { d.cmd.Disable(cap) }
+func (d *RecordingDevice) depthFunc(funcid uint32)       { d.cmd.DepthFunc(funcid) }
+func (d *RecordingDevice) blendFunc(sfactor, dfactor uint32) { d.cmd.BlendFunc(sfactor, dfactor) }
+func (d *RecordingDevice) cullFace(mode uint32)          { d.cmd.CullFace(mode) }
+
+func (d *RecordingDevice) depthMask(write bool) {
+  v := uint32(0) ; if write { v = 1 }
+  d.cmd.DepthMask(v)
+}
+
+func (d *RecordingDevice) createBuffer() GLBuffer {
+  b := GLBuffer{ id: glGenID() }
+  d.Buffers[b.id] = &RecordedBuffer{}
+  return b
+}
+
+func (d *RecordingDevice) deleteBuffer(b GLBuffer) {
+  if d.Buffers[b.id] == nil {
+    panicf("RecordingDevice.deleteBuffer: unknown buffer %d", b.id)
+  }
+  delete(d.Buffers, b.id)
+}
+
+func (d *RecordingDevice) bindBuffer(target uint32, buffer GLBuffer) {
+  if buffer.id != 0 && d.Buffers[buffer.id] == nil {
+    panicf("RecordingDevice.bindBuffer: unknown buffer %d", buffer.id)
+  }
+  switch target {
+  case GL_ARRAY_BUFFER:         d.arrayBuffer = buffer.id
+  case GL_ELEMENT_ARRAY_BUFFER: d.elementBuffer = buffer.id
+  }
+  d.cmd.BindBuffer(target, uint32(buffer.id))
+}
+
+// bound returns the buffer bound to target
+func (d *RecordingDevice) bound(target uint32) *RecordedBuffer {
+  id := d.arrayBuffer
+  if target == GL_ELEMENT_ARRAY_BUFFER {
+    id = d.elementBuffer
+  }
+  b := d.Buffers[id]
+  if b == nil {
+    panicf("RecordingDevice.bufferData: no buffer bound to target %d", target)
+  }
+  b.Target = target
+  return b
+}
+
+func (d *RecordingDevice) bufferDataF32(target uint32, data []float32, usage uint32) {
+  b := d.bound(target)
+  b.Usage = usage
+  b.F32 = append([]float32(nil), data...)
+  b.U16 = nil
+}
+
+func (d *RecordingDevice) bufferDataU16(target uint32, data []uint16, usage uint32) {
+  b := d.bound(target)
+  b.Usage = usage
+  b.U16 = append([]uint16(nil), data...)
+  b.F32 = nil
+}
+
+func (d *RecordingDevice) createTexture() GLTexture {
+  t := GLTexture{ id: glGenID() }
+  d.Textures[t.id] = &RecordedTexture{ Params: make(map[uint32]int32) }
+  return t
+}
+
+func (d *RecordingDevice) deleteTexture(t GLTexture) {
+  if d.Textures[t.id] == nil {
+    panicf("RecordingDevice.deleteTexture: unknown texture %d", t.id)
+  }
+  delete(d.Textures, t.id)
+}
+
+func (d *RecordingDevice) activeTexture(texture uint32) {
+  d.activeTex = texture
+  d.cmd.ActiveTexture(texture)
+}
+
+func (d *RecordingDevice) bindTexture(target uint32, t GLTexture) {
+  if t.id != 0 && d.Textures[t.id] == nil {
+    panicf("RecordingDevice.bindTexture: unknown texture %d", t.id)
+  }
+  if target == GL_TEXTURE_2D {
+    d.textures[d.activeTex] = t.id
+  }
+  d.cmd.BindTexture(target, uint32(t.id))
+}
+
+// boundTexture returns the texture bound to GL_TEXTURE_2D of the active texture unit
+func (d *RecordingDevice) boundTexture(target uint32) *RecordedTexture {
+  t := d.Textures[d.textures[d.activeTex]]
+  if target != GL_TEXTURE_2D || t == nil {
+    panicf("RecordingDevice: no texture bound to target %d", target)
+  }
+  return t
+}
+
+func (d *RecordingDevice) texParameteri(target, pname uint32, param int32) {
+  d.boundTexture(target).Params[pname] = param
+  d.cmd.TexParameteri(target, pname, param)
+}
+
+func (d *RecordingDevice) texImage2D(target, width, height uint32, pixels []uint8) {
+  t := d.boundTexture(target)
+  t.Width, t.Height = width, height
+  t.Pixels = append([]uint8(nil), pixels...)
+}
+
+func (d *RecordingDevice) createProgram(vSource, fSource string) (*GLProgram, error) {
+  p := &GLProgram{ id: glGenID(), dev: d }
+  rp := &RecordedProgram{
+    VertexSource:   vSource,
+    FragmentSource: fSource,
+    Attribs:        make(map[string]uint32),
+    uniforms:       make(map[string]bool),
+  }
+  for _, src := range []string{ vSource, fSource } {
+    for _, decl := range glslDecls(src, "uniform") {
+      rp.uniforms[decl] = true
+    }
+  }
+  for _, name := range glslDecls(vSource, "attribute") {
+    rp.Attribs[name] = uint32(len(rp.Attribs))
+  }
+  d.Programs[p.id] = rp
+  return p, nil
+}
+
+// glslDecls returns the names of variables declared with qualifier in GLSL source,
+// e.g. glslDecls(src, "uniform") => ["uTime", "uResolution"]
+func glslDecls(source, qualifier string) []string {
+  var names []string
+  for _, line := range strings.Split(source, "\n") {
+    fields := strings.Fields(strings.TrimSpace(line))
+    if len(fields) < 3 || fields[0] != qualifier {
+      continue
+    }
+    name := strings.TrimRight(fields[len(fields) - 1], ";")
+    if i := strings.IndexByte(name, '['); i != -1 {
+      name = name[:i]
+    }
+    names = append(names, name)
+  }
+  return names
+}
+
+func (d *RecordingDevice) program(p *GLProgram) *RecordedProgram {
+  rp := d.Programs[p.id]
+  if rp == nil {
+    panicf("RecordingDevice: program %d was not created on this device", p.id)
+  }
+  return rp
+}
+
+func (d *RecordingDevice) uniformLocation(p *GLProgram, name string) (GLUniform, error) {
+  if !d.program(p).uniforms[name] {
+    return 0, errorf("uniform %#v not found", name)
+  }
+  u := GLUniform(glGenID())
+  d.Uniforms[u] = RecordedUniform{ Program: p.id, Name: name }
+  return u, nil
+}
+
+func (d *RecordingDevice) attribLocation(p *GLProgram, name string) (uint32, error) {
+  if location, ok := d.program(p).Attribs[name]; ok {
+    return location, nil
+  }
+  return 0, errorf("attribute %#v not found", name)
+}
+
+func (d *RecordingDevice) useProgram(p *GLProgram) bool {
+  d.program(p)
+  d.cmd.UseProgram(uint32(p.id))
+  return true
+}
+
+func (d *RecordingDevice) uniformMatrix4fv(location GLUniform, transpose bool, value [16]float32) {
+  transpose_ := uint32(0) ; if transpose { transpose_ = 1 }
+  d.cmd.UniformMatrix(uint32(location), transpose_, value[:])
+}
+
+func (d *RecordingDevice) uniformf(location GLUniform, value ...float32) {
+  d.cmd.Uniformf(uint32(location), value...)
+}
+
+func (d *RecordingDevice) uniformi(location GLUniform, value ...int32) {
+  d.cmd.Uniformi(uint32(location), value...)
+}
+
+func (d *RecordingDevice) vertexAttribPointer(
+  index, size uint32, typ GLenum, normalized bool, stride, offset uint32) {
+  normalized_ := uint32(0) ; if normalized { normalized_ = 1 }
+  d.cmd.VertexAttribPointer(index, size, typ, normalized_, stride, offset)
+}
+
+func (d *RecordingDevice) enableVertexAttribArray(index uint32) {
+  d.cmd.EnableVertexAttribArray(index)
+}
+
+func (d *RecordingDevice) disableVertexAttribArray(index uint32) {
+  d.cmd.DisableVertexAttribArray(index)
+}
+
+func (d *RecordingDevice) drawArrays(mode, first, count uint32) {
+  d.cmd.DrawArrays(mode, first, count)
+}
+
+func (d *RecordingDevice) drawElements(mode, count, kind, offset uint32) {
+  d.cmd.DrawElements(mode, count, kind, offset)
+}
+
+func (d *RecordingDevice) flush() {
+  d.Flushes++
+}
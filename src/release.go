@@ -0,0 +1,8 @@
+// +build !debug
+
+package main
+
+// DEBUG is true when built with -tags debug, which enables extra runtime checks
+const DEBUG = false
+
+func checkAlive(em *EntManager, e Ent, skip int) {}
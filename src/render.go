@@ -1,30 +1,49 @@
 package main
 
-import (
-  "syscall/js"
-)
+import "sort"
 
 type Renderer struct {
-  gl         *GLContext
+  gl         GraphicsDevice
   pixelRatio float32
   width      uint32    // width of canvas in display points
   height     uint32    // height of canvas in display points
   resolution Vec2      // rendering size in pixels (may be smaller than size*pixelRatio)
   needResize bool      // true when size or pixelRatio has changed and a resize() call is needed
   pointer    Vec3      // position of pointer in canvas space. xy: pos, z: click
-  canvasel   js.Value
-  projectionMatrix Matrix4
+  projectionMatrix Matrix4 // of the camera, for the frame being rendered
+  viewMatrix       Matrix4 // of the camera, for the frame being rendered
+  time       float32   // time of the frame being rendered (World.Clock.RenderTime)
+  frame      uint64    // incremented for each rendered frame
+
+  // well-known uniforms of programs, keyed by GLProgram.id (see useProgram)
+  programs map[uintptr]*programState
+
+  // device state set by useMaterial in the frame being rendered
+  material        *Material // material in use, or nil at the start of a frame
+  materialVersion uint64    // version of material's parameters when it was applied
+  state           RenderState
+
+  world      *World
+  Camera     Ent             // camera entity to render from (see activeCamera)
+  Drawables  DrawableSystem  // entities drawn by the renderer (see AddToWorld)
+  Movement   *MovementSystem // optional; moving nodes are interpolated (see nodeTransform)
+  query      *Query          // entities with a transform node and a drawable
+  queue      []renderItem    // reused by drawQueue
 }
 
+// renderItem is an entry of the render queue built each frame
+type renderItem struct {
+  drawable  Drawable
+  material  *Material
+  modelView Matrix4
+}
 
-func NewRenderer(canvas js.Value, width, height uint32, pixelRatio float32) (*Renderer, error) {
-  gl, err := NewGLContext(canvas)
-  if err != nil {
-    return nil, err
-  }
-  r := &Renderer{ gl: gl }
+
+// NewRenderer returns a renderer that draws with dev, e.g. a GLContext
+func NewRenderer(dev GraphicsDevice, width, height uint32, pixelRatio float32) *Renderer {
+  r := &Renderer{ gl: dev, programs: make(map[uintptr]*programState) }
   r.setSize(width, height, pixelRatio)
-  return r, nil
+  return r
 }
 
 
@@ -42,16 +61,8 @@ func (r *Renderer) setSize(width, height uint32, pixelRatio float32) {
   r.resolution[0] = float32(resx)
   r.resolution[1] = float32(resy)
 
-  // Update projection matrix.
-  // We use a perspective matrix; a special matrix that is used to simulate the
-  // distortion of perspective in a camera.
-  // Our field of view is 55 degrees, with a width/height ratio that matches the
-  // display size of the canvas and we only want to see objects between 0.1 units
-  // and 100 units away from the camera.
-  const fov float32         = 45.0 * (PI / 180.0)   // in radians
-  const zNear, zFar float32 = 0.1, 100.0
-  aspect := r.resolution[0] / r.resolution[1]
-  r.projectionMatrix = Matrix4Perspective(fov, aspect, zNear, zFar)
+  // Note: the projection matrix depends on the aspect ratio and is computed from the
+  // active camera for each frame (see render)
 
   // mark the renderer as needing resize, like calling gl.viewport()
   r.needResize = true
@@ -120,116 +131,30 @@ void main() {
 `
 
 
-// program constants, for now (TODO move into GLProgram)
-var (
-  // vertex shader
-  aVertexPosition   uint32
-  uModelViewMatrix  GLUniform
-  uProjectionMatrix GLUniform
-
-  // fragment shader
-  uResolution      GLUniform // vec2  // Canvas size, viewport resolution (in pixels)
-  uPointer         GLUniform // vec3  // Pointer pixel coords
-  uTime            GLUniform // float // Time in seconds since load
-
-  // cube shader
-  cube_uProjectionMatrix GLUniform
-  cube_uResolution GLUniform
-)
-
-var (
-  program *GLProgram
-  planeobj *GLPlane
-  planeobj2 *GLPlane
-
-  cubeProgram *GLProgram
-  cubeobj1 *GLCube
-  cubeobj2 *GLCube
-)
-
-
 func (r *Renderer) init() {
   logf("Renderer.init resolution %v, pixelRatio %.1f", r.resolution, r.pixelRatio)
-
-  r.initShaders()
-  // r.initBuffers()
-  // r.initTextures()
-
-  // make planes
-  var err error
-  planeobj, err = NewGLPlane(program)
-  planeobj2, err = NewGLPlane(program)
-  planeobj2.absoluteTransform.Translate(0.4, 0.4, -1.0).RotateZ(1.0)
-
-  // make cubes
-  cubeobj1, err = NewGLCube(cubeProgram)
-  cubeobj1.absoluteTransform.Translate(0, 0, -5.5)
-
-  cubeobj2, err = NewGLCube(cubeProgram)
-  // cubeobj2.absoluteTransform.ScaleMut(1.5, 0.5, 0.5)
-  cubeobj2.absoluteTransform.Translate(0.5, 0.5, -5.5)
-  if err != nil {
-    panic(err)
-  }
 }
 
-func (r *Renderer) start() {
-  // update pointer
-  onPointerEvent := func (_ Event, data ...uint32) {
-    r.pointer[0] = host.pointer.x * r.pixelRatio
-    r.pointer[1] = host.pointer.y * r.pixelRatio
-    if host.pointer.buttons == 0 {
-      r.pointer[2] = 0.0
-    } else {
-      r.pointer[2] = 1.0
-    }
-  }
-  host.events.Listen(EVPointerMove, onPointerEvent)
-  host.events.Listen(EVPointerDown, onPointerEvent)
-  host.events.Listen(EVPointerUp, onPointerEvent)
-
-  // render on each frame
-  host.events.Listen(EVAnimationFrame, func (_ Event, _ ...uint32) {
-    host.UpdateAnimationStats()
-    r.render(float32(host.scenetime))
-  })
-  r.render(0.0)
+// AddToWorld makes the renderer draw the entities of w that have a drawable and a
+// transform node, once per World.Update during PhaseRender.
+func (r *Renderer) AddToWorld(w *World) {
+  r.world = w
+  r.Drawables.Init(w)
+  w.AddSystem(&r.Drawables)
+  r.query = w.Query(&w.TransformSystem.store, &r.Drawables.ComponentStore)
+  w.Schedule(PhaseRender, "render", r)
 }
 
-
-func (r *Renderer) initShaders() {
-  // Create shader program
-  var err error
-  program, err = NewGLProgramSource(r.gl, vertexShaderSrc, fragmentShaderSrc)
-  if err != nil {
-    panic(err)
-  }
-
-  cubeProgram, err = NewGLProgramSource(r.gl, cubeVertexShaderSrc, cubeFragmentShaderSrc)
-  if err != nil {
-    panic(err)
-  }
-
-  cube_uProjectionMatrix, err = cubeProgram.getUniformLocation("uProjectionMatrix")
-  cube_uResolution, err = cubeProgram.getUniformLocation("uResolution")
-
-  // attribLocations
-  aVertexPosition, err = program.getAttribLocation("aVertexPosition")
-
-  // uniformLocations
-  uProjectionMatrix, err = program.getUniformLocation("uProjectionMatrix")
-  uModelViewMatrix, err  = program.getUniformLocation("uModelViewMatrix")
-  uResolution, err       = program.getUniformLocation("uResolution")
-  uPointer, err          = program.getUniformLocation("uPointer")
-  uTime, err             = program.getUniformLocation("uTime")
-  if err != nil {
-    panic(err)
-  }
+// Update renders a frame. Called by World.Update during PhaseRender.
+func (r *Renderer) Update(time float64) {
+  r.render(float32(time))
 }
 
 
 func (r *Renderer) render(time float32) {
   // logf("Renderer.render")
+  r.time = time
+  r.frame++
   gl := r.gl
   width, height := r.resolution[0], r.resolution[1]
 
@@ -238,49 +163,224 @@ func (r *Renderer) render(time float32) {
     r.gl.viewport(0, 0, uint32(width), uint32(height))
   }
 
+  // projection and view from the camera, with a width/height ratio that matches the
+  // display size of the canvas
+  camera, cameraEnt := r.activeCamera()
+  r.projectionMatrix = camera.ProjectionMatrix(width / height)
+  r.viewMatrix = Matrix4Identity
+  if cameraEnt != NilEnt {
+    r.viewMatrix = r.world.Cameras.ViewMatrix(cameraEnt)
+  }
+
   gl.clearColor(0.2, 0.25, 0.3, 1.0) // Clear to color, fully opaque
   gl.clearDepth(1.0)                 // Clear everything
   gl.enable(GL_DEPTH_TEST)              // Enable depth testing
   gl.depthFunc(GL_LEQUAL)               // Near things obscure far things
+  gl.depthMask(true)                    // clear the depth buffer too (see RenderState)
+
+  // materials set their render state as needed
+  r.material = nil
 
   // Clear the canvas before we start drawing on it.
   gl.clear(GL_COLOR_BUFFER_BIT | GL_DEPTH_BUFFER_BIT)
 
-  // TODO: figure out how to setup the projection matrix and uniforms for whatever programs
-  // are being used by drawables automatically and efficiently.
-  // I.e. plane and cube uses different shader programs and need to have their.
-  //
-  // For example, we could do something like this:
-  //   r.useProgram(p):
-  //     gl.useProgram(p):  // returns true when program was not already active
-  //       if currframe != progInitFrame[p]:
-  //         progInitFrame[p] = currframe
-  //         gl.uniformMatrix4fv(uProjectionMatrix, false, r.projectionMatrix)
-  //         gl.uniformf(uResolution, r.resolution[0], r.resolution[1])
-  //
-  //   drawable.Draw(r):
-  //     r.useProgram(drawable.program)
-  //
-  // For now, just setup all programs manually, hard coded.
-
-  // Tell WebGL to use our program for planes when drawing
-  gl.useProgram(program)
-  gl.uniformMatrix4fv(uProjectionMatrix, false, r.projectionMatrix)
-  gl.uniformf(uResolution, r.resolution[0], r.resolution[1])
-  gl.uniformf(uTime, time)
-  gl.uniformf(uPointer, r.pointer[:]...)
-
-  // planeobj.Draw(r)
-  // planeobj2.Draw(r)
-
-  // Tell WebGL to use our program for meshes when drawing
-  gl.useProgram(cubeProgram)
-  gl.uniformMatrix4fv(cube_uProjectionMatrix, false, r.projectionMatrix)
-  gl.uniformf(cube_uResolution, r.resolution[0], r.resolution[1])
-
-  cubeobj1.Draw(r)
-  // cubeobj2.Draw(r)
-
-  gl.useProgram(program)
-  // planeobj2.Draw(r)
+  r.drawQueue()
+
+  // send the frame's GL commands to the host
+  gl.flush()
+}
+
+
+// activeCamera returns the camera to render from and its entity: r.Camera if it's a
+// live entity with a camera, or else the first camera of the world. When the world has
+// no cameras, DefaultCamera is returned with NilEnt, for a camera at the origin.
+func (r *Renderer) activeCamera() (*Camera, Ent) {
+  if r.world != nil {
+    cameras := &r.world.Cameras
+    if r.Camera != NilEnt && r.world.Ents.IsAlive(r.Camera) {
+      if c := cameras.Get(r.Camera); c != nil {
+        return c, r.Camera
+      }
+    }
+    if cameras.Len() > 0 {
+      return &cameras.data[0], cameras.EntAt(0)
+    }
+  }
+  return &DefaultCamera, NilEnt
+}
+
+// programState holds the locations of the well-known uniforms that the renderer sets
+// for a program, and which material's parameters the program holds. Programs only
+// need to declare the uniforms they use:
+//
+//   uniform mat4        uProjectionMatrix;
+//   uniform vec2        uResolution;  // viewport resolution (in pixels)
+//   uniform highp float uTime;        // time in seconds
+//   uniform vec3        uPointer;     // pointer pixel coords. xy: current, z: click
+//   uniform mat4        uModelViewMatrix;
+//
+// All but uModelViewMatrix are set the first time the program is used in a frame.
+// uModelViewMatrix is set for each drawable.
+type programState struct {
+  frame            uint64 // frame in which the frame uniforms were last set
+  projectionMatrix GLUniform
+  resolution       GLUniform
+  time             GLUniform
+  pointer          GLUniform
+  modelViewMatrix  GLUniform
+  has              [5]bool // true for each uniform declared by the program, in order
+
+  material        *Material // material whose parameters were last set
+  materialVersion uint64
+}
+
+// useProgram makes p the current program. The first time p is used in a frame, the
+// frame uniforms it declares are set.
+func (r *Renderer) useProgram(p *GLProgram) *programState {
+  r.gl.useProgram(p)
+  ps := r.programs[p.id]
+  if ps == nil {
+    ps = &programState{}
+    for i, u := range []struct{ name string; loc *GLUniform }{
+      { "uProjectionMatrix", &ps.projectionMatrix },
+      { "uResolution", &ps.resolution },
+      { "uTime", &ps.time },
+      { "uPointer", &ps.pointer },
+      { "uModelViewMatrix", &ps.modelViewMatrix },
+    } {
+      var err error
+      *u.loc, err = p.getUniformLocation(u.name)
+      ps.has[i] = err == nil
+    }
+    r.programs[p.id] = ps
+  }
+  if ps.frame == r.frame {
+    return ps
+  }
+  ps.frame = r.frame
+  gl := r.gl
+  if ps.has[0] {
+    gl.uniformMatrix4fv(ps.projectionMatrix, false, r.projectionMatrix)
+  }
+  if ps.has[1] {
+    gl.uniformf(ps.resolution, r.resolution[0], r.resolution[1])
+  }
+  if ps.has[2] {
+    gl.uniformf(ps.time, r.time)
+  }
+  if ps.has[3] {
+    gl.uniformf(ps.pointer, r.pointer[:]...)
+  }
+  return ps
+}
+
+// useMaterial applies m before drawing with it: its render state, program and
+// parameters. Only what differs from the material used before is set; render state is
+// compared field by field and parameters are only set when the program holds those of
+// another material, or of an older version of m.
+func (r *Renderer) useMaterial(m *Material) *programState {
+  if m == r.material && m.version == r.materialVersion {
+    return r.programs[m.Program.id]
+  }
+  if r.material == nil {
+    m.State.apply(r.gl, nil) // state unknown at the start of a frame
+  } else {
+    m.State.apply(r.gl, &r.state)
+  }
+  r.state = m.State
+  ps := r.useProgram(m.Program)
+  if ps.material != m || ps.materialVersion != m.version {
+    m.setUniforms()
+    ps.material, ps.materialVersion = m, m.version
+  }
+  m.bindTextures()
+  r.material, r.materialVersion = m, m.version
+  return ps
+}
+
+// nodeTransform returns the absolute transform of n to draw with. When n or any of its
+// ancestors has movement data in r.Movement, it is placed at the movement position
+// interpolated between the previous and current simulation tick by World.Clock.Alpha,
+// which makes motion smooth when frames don't line up with ticks. Children follow the
+// interpolated position of a moving parent.
+func (r *Renderer) nodeTransform(n *TransformNode) *Matrix4 {
+  if r.Movement == nil {
+    return &n.absolute
+  }
+  m, moved := r.interpolatedTransform(n, float32(r.world.Clock.Alpha))
+  if !moved {
+    return &n.absolute
+  }
+  return &m
+}
+
+// interpolatedTransform returns the absolute transform of n with the movement positions
+// of n and its ancestors interpolated by alpha. moved is false, and m is not computed,
+// when neither n nor its ancestors have movement data.
+func (r *Renderer) interpolatedTransform(n *TransformNode, alpha float32) (m Matrix4, moved bool) {
+  parent := r.world.TransformSystem.node(n.parent)
+  var parentAbsolute Matrix4
+  parentMoved := false
+  if parent != nil {
+    parentAbsolute, parentMoved = r.interpolatedTransform(parent, alpha)
+  }
+  position := n.position
+  if i := r.Movement.Index(n.ent); i != -1 {
+    p := r.Movement.At(i).InterpolatedPosition(alpha)
+    position = Vec3{ p[0], p[1], n.position[2] }
+  } else if !parentMoved {
+    return m, false
+  }
+  m = Matrix4Compose(position, n.rotation, n.scale)
+  if parent != nil {
+    if !parentMoved {
+      parentAbsolute = parent.absolute
+    }
+    m = parentAbsolute.Mul4(&m)
+  }
+  return m, true
+}
+
+// drawQueue draws all entities with a drawable and a transform node, using the
+// absolute transform of each node as the model matrix, as seen from the camera.
+// Opaque materials are drawn first, grouped by program and material. Blended materials
+// are drawn last, from back to front.
+func (r *Renderer) drawQueue() {
+  if r.world == nil {
+    return
+  }
+  r.queue = r.queue[:0]
+  q := r.query
+  for q.Reset(); q.Next(); {
+    node := q.Get(0).(*TransformNode)
+    d := r.Drawables.At(q.Index(1))
+    r.queue = append(r.queue, renderItem{
+      drawable:  d,
+      material:  d.Material(),
+      modelView: r.viewMatrix.Mul4(r.nodeTransform(node)),
+    })
+  }
+  sort.SliceStable(r.queue, func(i, j int) bool {
+    a, b := &r.queue[i], &r.queue[j]
+    ablend, bblend := a.material.State.Blend != BlendNone, b.material.State.Blend != BlendNone
+    if ablend != bblend {
+      return bblend
+    }
+    if ablend {
+      return a.modelView[14] < b.modelView[14] // view space z; farther is more negative
+    }
+    if a.material.Program.id != b.material.Program.id {
+      return a.material.Program.id < b.material.Program.id
+    }
+    return a.material.id < b.material.id
+  })
+  gl := r.gl
+  for i := range r.queue {
+    item := &r.queue[i]
+    ps := r.useMaterial(item.material)
+    if ps.has[4] {
+      gl.uniformMatrix4fv(ps.modelViewMatrix, false, item.modelView)
+    }
+    item.drawable.Draw(r, &item.modelView)
+  }
 }
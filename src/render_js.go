@@ -0,0 +1,21 @@
+// +build js,wasm
+
+package main
+
+// start makes the renderer track the pointer and renders a first frame
+func (r *Renderer) start() {
+  // update pointer
+  onPointerEvent := func (_ Event, data ...uint32) {
+    r.pointer[0] = host.pointer.x * r.pixelRatio
+    r.pointer[1] = host.pointer.y * r.pixelRatio
+    if host.pointer.buttons == 0 {
+      r.pointer[2] = 0.0
+    } else {
+      r.pointer[2] = 1.0
+    }
+  }
+  host.events.Listen(EVPointerMove, onPointerEvent)
+  host.events.Listen(EVPointerDown, onPointerEvent)
+  host.events.Listen(EVPointerUp, onPointerEvent)
+  r.render(0.0)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+  "strings"
+  "testing"
+)
+
+// programCommands returns the program, uniform and draw commands recorded by dev, e.g.
+// "UniformMatrix uModelViewMatrix". Other commands are left out.
+func programCommands(dev *RecordingDevice) []string {
+  var cmds []string
+  for _, c := range dev.Commands() {
+    switch c.Op {
+    case GLOpUseProgram, GLOpDrawArrays, GLOpDrawElements:
+      cmds = append(cmds, c.Op.String())
+    case GLOpUniformMatrix, GLOpUniformf, GLOpUniformi:
+      cmds = append(cmds, c.Op.String() + " " + dev.Uniforms[GLUniform(c.Args[0])].Name)
+    }
+  }
+  return cmds
+}
+
+func TestRendererFrame(t *testing.T) {
+  w := newTestWorld()
+  dev := NewRecordingDevice()
+  r := NewRenderer(dev, 320, 240, 1)
+  r.AddToWorld(w)
+  createRenderDemo(w, r)
+
+  // a second cube with the same material, drawn after the first one
+  cube := w.Names.Find("cube")
+  cube2 := w.Ents.Alloc()
+  tm := Matrix4Identity
+  tm.Translate(1, 0, -2)
+  w.CreateNode(cube2, tm)
+  r.Drawables.Assoc(cube2, r.Drawables.Get(cube))
+
+  // the program and the frame uniforms are set once; each cube sets its model-view
+  // matrix and draws
+  want := []string{
+    "UseProgram",
+    "UniformMatrix uProjectionMatrix",
+    "Uniformf uResolution",
+    "UniformMatrix uModelViewMatrix",
+    "DrawElements",
+    "UniformMatrix uModelViewMatrix",
+    "DrawElements",
+  }
+  for frame := 0; frame < 2; frame++ {
+    dev.Reset()
+    w.Update(float64(frame))
+    got := programCommands(dev)
+    if strings.Join(got, "\n") != strings.Join(want, "\n") {
+      t.Fatalf("frame %d: commands:\n  %s\nexpected:\n  %s",
+        frame, strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+    }
+  }
+
+  // matrices are those of the camera and of each node, in either order, and draws
+  // use the material's program
+  program := r.Drawables.Get(cube).Material().Program.id
+  view := w.Cameras.ViewMatrix(r.Camera)
+  wantModelView := map[Ent]Matrix4{
+    cube:  view.Mul4(&w.Get(cube).absolute),
+    cube2: view.Mul4(&w.Get(cube2).absolute),
+  }
+  for _, c := range dev.Commands() {
+    if c.Op == GLOpDrawElements && c.Program != program {
+      t.Errorf("%s recorded with program %d, expected %d", c, c.Program, program)
+    }
+    if c.Op != GLOpUniformMatrix {
+      continue
+    }
+    switch m := c.UniformMatrix4(); dev.Uniforms[GLUniform(c.Args[0])].Name {
+    case "uProjectionMatrix":
+      camera, _ := r.activeCamera()
+      if want := camera.ProjectionMatrix(320.0 / 240.0); m != want {
+        t.Errorf("projection matrix %v, expected %v", m, want)
+      }
+    case "uModelViewMatrix":
+      found := false
+      for e, want := range wantModelView {
+        if approxMatrix4(m, want) {
+          delete(wantModelView, e)
+          found = true
+          break
+        }
+      }
+      if !found {
+        t.Errorf("unexpected model-view matrix %v", m)
+      }
+    }
+  }
+  if len(wantModelView) > 0 {
+    t.Errorf("model-view matrices not set: %v", wantModelView)
+  }
+}
+
+func approxMatrix4(a, b Matrix4) bool {
+  for i := range a {
+    if abs32(a[i] - b[i]) > 1e-5 {
+      return false
+    }
+  }
+  return true
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+  "bufio"
+  "bytes"
+  "encoding"
+  "encoding/binary"
+  "encoding/json"
+  "io"
+  "io/ioutil"
+  "math"
+  "reflect"
+  "strings"
+)
+
+// SceneVersion is the version of the scene file format written by WriteScene.
+// Files with a greater version are rejected by ReadScene.
+const SceneVersion = 2
+
+type SceneFormat int
+const (
+  SceneJSON   = SceneFormat(iota) // human-readable JSON
+  SceneBinary                     // compact binary
+)
+
+// Scene is a snapshot of entities with their transforms and registered components.
+// Entities are identified by their index in Entities rather than by Ent, since Ent
+// values are specific to a World and are reallocated when a scene is spawned.
+// Parents always precede their children in Entities and siblings appear in order,
+// which allows a scene to be spawned in a single pass.
+type Scene struct {
+  Entities []SceneEntity
+}
+
+type SceneEntity struct {
+  Parent     int                    // index of parent in Scene.Entities, or -1
+  Name       string                 // optional (see World.Names)
+  Tags       []string               // optional (see World.Tags)
+  Transform  *SceneTransform        // nil if the entity has no transform node
+  Components map[string]interface{} // values keyed by registered component name
+}
+
+type SceneTransform struct {
+  Position Vec3
+  Rotation Quat
+  Scale    Vec3
+}
+
+// CaptureScene returns a snapshot of roots and all of their descendants.
+// If no roots are given, all entities with a transform, a name, tags or a registered
+// component are captured.
+func (w *World) CaptureScene(roots ...Ent) *Scene {
+  c := sceneCapture{ w: w, s: &Scene{}, index: make(map[Ent]int) }
+  ts := &w.TransformSystem
+  if len(roots) == 0 {
+    for i, e := range ts.store.Ents() {
+      if ts.nodes[i].parent == NilEnt {
+        c.addSubtree(e, -1)
+      }
+    }
+    stores := []*ComponentStore{ &w.Names.ComponentStore, &w.Tags.ComponentStore }
+    for _, ct := range w.components {
+      stores = append(stores, ct.Store)
+    }
+    for _, store := range stores {
+      for _, e := range store.Ents() {
+        if _, ok := c.index[e]; !ok && w.Ents.IsAlive(e) {
+          c.add(e, -1)
+        }
+      }
+    }
+  } else {
+    for _, e := range roots {
+      c.addSubtree(e, -1)
+    }
+  }
+  return c.s
+}
+
+type sceneCapture struct {
+  w     *World
+  s     *Scene
+  index map[Ent]int // Ent => index in s.Entities
+}
+
+// addSubtree adds e and its descendants. Entities that were freed without World.Destroy
+// but are still linked into the hierarchy are skipped along with their descendants.
+func (c *sceneCapture) addSubtree(e Ent, parent int) {
+  if !c.w.Ents.IsAlive(e) {
+    return
+  }
+  i := c.add(e, parent)
+  ts := &c.w.TransformSystem
+  if n := ts.node(e); n != nil {
+    for child := n.firstChild; child != NilEnt; child = ts.node(child).nextSibling {
+      c.addSubtree(child, i)
+    }
+  }
+}
+
+func (c *sceneCapture) add(e Ent, parent int) int {
+  se := SceneEntity{ Parent: parent, Name: c.w.Names.Get(e) }
+  se.Tags = c.w.Tags.Names(c.w.Tags.Get(e))
+  if n := c.w.TransformSystem.Get(e); n != nil {
+    se.Transform = &SceneTransform{ n.position, n.rotation, n.scale }
+  }
+  for _, ct := range c.w.components {
+    if p := ct.Store.Get(e); p != nil {
+      if se.Components == nil {
+        se.Components = make(map[string]interface{})
+      }
+      se.Components[ct.Name] = reflect.ValueOf(p).Elem().Interface()
+    }
+  }
+  i := len(c.s.Entities)
+  c.s.Entities = append(c.s.Entities, se)
+  c.index[e] = i
+  return i
+}
+
+// SpawnScene creates new entities for all entities in s and returns them in the same
+// order as s.Entities. Root entities with a transform are added as children of parent,
+// unless parent is NilEnt. s is validated before any entity is created, so when an
+// error is returned the world is left unchanged.
+func (w *World) SpawnScene(s *Scene, parent Ent) ([]Ent, error) {
+  if err := w.validateScene(s); err != nil {
+    return nil, err
+  }
+  ents := make([]Ent, len(s.Entities))
+  for i, se := range s.Entities {
+    e := w.Ents.Alloc()
+    ents[i] = e
+    if se.Name != "" {
+      w.Names.SetName(e, se.Name)
+    }
+    if len(se.Tags) > 0 {
+      w.Tags.AddTags(e, se.Tags...)
+    }
+    if t := se.Transform; t != nil {
+      local := Matrix4Compose(t.Position, t.Rotation, t.Scale)
+      n := w.TransformSystem.CreateNode(e, local)
+      // keep the values as-is rather than decomposed from local, which is lossy
+      n.position, n.rotation, n.scale = t.Position, t.Rotation, t.Scale
+      if se.Parent >= 0 {
+        if s.Entities[se.Parent].Transform != nil {
+          w.TransformSystem.AppendChild(ents[se.Parent], e)
+        }
+      } else if parent != NilEnt {
+        w.TransformSystem.AppendChild(parent, e)
+      }
+    }
+    for name, value := range se.Components {
+      w.Component(name).Set(e, value)
+    }
+  }
+  return ents, nil
+}
+
+// validateScene returns an error if s can't be spawned in w
+func (w *World) validateScene(s *Scene) error {
+  newTags := map[string]bool{}
+  for i, se := range s.Entities {
+    if err := s.validateParent(i); err != nil {
+      return err
+    }
+    if strings.Contains(se.Name, "/") {
+      return errorf("entity %d: name %q contains \"/\"", i, se.Name)
+    }
+    for _, tag := range se.Tags {
+      if _, ok := w.Tags.Lookup(tag); !ok && !newTags[tag] {
+        if len(w.Tags.names) + len(newTags) == MaxTags {
+          return errorf("entity %d: too many tags (max %d)", i, MaxTags)
+        }
+        newTags[tag] = true
+      }
+    }
+    for name, value := range se.Components {
+      store := w.Component(name)
+      if store == nil {
+        return errorf("entity %d: unknown component %q", i, name)
+      }
+      if t := reflect.TypeOf(value); t != store.Type() {
+        return errorf("entity %d: component %q: value of type %v is not a %v",
+          i, name, t, store.Type())
+      }
+    }
+  }
+  return nil
+}
+
+// SaveScene writes all entities of the world to out
+func (w *World) SaveScene(out io.Writer, format SceneFormat) error {
+  return w.WriteScene(out, w.CaptureScene(), format)
+}
+
+// LoadScene reads a scene from in, in either format, and spawns its entities.
+func (w *World) LoadScene(in io.Reader) ([]Ent, error) {
+  s, err := w.ReadScene(in)
+  if err != nil {
+    return nil, err
+  }
+  return w.SpawnScene(s, NilEnt)
+}
+
+// WriteScene encodes s to out.
+//
+// Component values are encoded with their MarshalJSON or MarshalBinary methods, when
+// available, or else with encoding/json or encoding/binary.
+func (w *World) WriteScene(out io.Writer, s *Scene, format SceneFormat) error {
+  switch format {
+  case SceneJSON:
+    return writeSceneJSON(out, s)
+  case SceneBinary:
+    return writeSceneBinary(out, s)
+  }
+  return errorf("invalid scene format %d", format)
+}
+
+// ReadScene decodes a scene from in. The format is detected automatically.
+// Components are decoded into the types of the world's registered components.
+func (w *World) ReadScene(in io.Reader) (*Scene, error) {
+  r := bufio.NewReader(in)
+  magic, _ := r.Peek(len(sceneMagic))
+  if string(magic) == sceneMagic {
+    return w.readSceneBinary(r)
+  }
+  return w.readSceneJSON(r)
+}
+
+func (w *World) decodeComponent(name string, data []byte, format SceneFormat) (interface{}, error) {
+  store := w.Component(name)
+  if store == nil {
+    return nil, errorf("unknown component %q", name)
+  }
+  v := reflect.New(store.Type())
+  var err error
+  if format == SceneJSON {
+    err = json.Unmarshal(data, v.Interface())
+  } else if u, ok := v.Interface().(encoding.BinaryUnmarshaler); ok {
+    err = u.UnmarshalBinary(data)
+  } else {
+    err = binary.Read(bytes.NewReader(data), binary.LittleEndian, v.Interface())
+  }
+  if err != nil {
+    return nil, errorf("component %q: %v", name, err)
+  }
+  return v.Elem().Interface(), nil
+}
+
+func (s *Scene) validateParent(i int) error {
+  if p := s.Entities[i].Parent; p >= i || p < -1 {
+    return errorf("entity %d has invalid parent %d", i, p)
+  }
+  return nil
+}
+
+// -----------------------------------------------------------------------------
+// JSON format
+//
+//   {
+//     "version": 2,
+//     "entities": [
+//       { "name": "player",
+//         "tags": ["controllable"],
+//         "transform": { "position": [0,0,0], "rotation": [0,0,0,1], "scale": [1,1,1] },
+//         "components": { "movement": { ... } } },
+//       { "parent": 0, "name": "gun", "transform": { ... } },
+//     ]
+//   }
+//
+// Rotation is a quaternion as [x, y, z, w].
+
+type sceneJSON struct {
+  Version  int               `json:"version"`
+  Entities []sceneEntityJSON `json:"entities"`
+}
+
+type sceneEntityJSON struct {
+  Parent     *int                       `json:"parent,omitempty"`
+  Name       string                     `json:"name,omitempty"`
+  Tags       []string                   `json:"tags,omitempty"`
+  Transform  *sceneTransformJSON        `json:"transform,omitempty"`
+  Components map[string]json.RawMessage `json:"components,omitempty"`
+}
+
+type sceneTransformJSON struct {
+  Position [3]float32 `json:"position"`
+  Rotation [4]float32 `json:"rotation"`
+  Scale    [3]float32 `json:"scale"`
+}
+
+// UnmarshalJSON defaults fields that are missing to those of the identity transform
+func (t *sceneTransformJSON) UnmarshalJSON(data []byte) error {
+  type plain sceneTransformJSON // without this method
+  v := plain{ Rotation: [4]float32{ 0, 0, 0, 1 }, Scale: [3]float32{ 1, 1, 1 } }
+  if err := json.Unmarshal(data, &v); err != nil {
+    return err
+  }
+  *t = sceneTransformJSON(v)
+  return nil
+}
+
+func writeSceneJSON(out io.Writer, s *Scene) error {
+  doc := sceneJSON{ Version: SceneVersion, Entities: make([]sceneEntityJSON, len(s.Entities)) }
+  for i, se := range s.Entities {
+    je := &doc.Entities[i]
+    if se.Parent >= 0 {
+      parent := se.Parent
+      je.Parent = &parent
+    }
+    je.Name = se.Name
+    je.Tags = se.Tags
+    if t := se.Transform; t != nil {
+      je.Transform = &sceneTransformJSON{
+        Position: t.Position,
+        Rotation: [4]float32{ t.Rotation.V[0], t.Rotation.V[1], t.Rotation.V[2], t.Rotation.W },
+        Scale:    t.Scale,
+      }
+    }
+    if len(se.Components) > 0 {
+      je.Components = make(map[string]json.RawMessage, len(se.Components))
+      for name, value := range se.Components {
+        data, err := json.Marshal(value)
+        if err != nil {
+          return errorf("component %q: %v", name, err)
+        }
+        je.Components[name] = data
+      }
+    }
+  }
+  data, err := json.MarshalIndent(&doc, "", "  ")
+  if err != nil {
+    return err
+  }
+  _, err = out.Write(append(data, '\n'))
+  return err
+}
+
+func (w *World) readSceneJSON(in io.Reader) (*Scene, error) {
+  var doc sceneJSON
+  if err := json.NewDecoder(in).Decode(&doc); err != nil {
+    return nil, err
+  }
+  if doc.Version < 1 || doc.Version > SceneVersion {
+    return nil, errorf("unsupported scene version %d", doc.Version)
+  }
+  s := &Scene{ Entities: make([]SceneEntity, len(doc.Entities)) }
+  for i, je := range doc.Entities {
+    se := &s.Entities[i]
+    se.Parent = -1
+    if je.Parent != nil {
+      se.Parent = *je.Parent
+      if err := s.validateParent(i); err != nil {
+        return nil, err
+      }
+    }
+    se.Name = je.Name
+    se.Tags = je.Tags
+    if t := je.Transform; t != nil {
+      se.Transform = &SceneTransform{
+        Position: t.Position,
+        Rotation: Quat{ W: t.Rotation[3], V: [3]float32{ t.Rotation[0], t.Rotation[1], t.Rotation[2] } },
+        Scale:    t.Scale,
+      }
+    }
+    if len(je.Components) > 0 {
+      se.Components = make(map[string]interface{}, len(je.Components))
+      for name, data := range je.Components {
+        value, err := w.decodeComponent(name, data, SceneJSON)
+        if err != nil {
+          return nil, err
+        }
+        se.Components[name] = value
+      }
+    }
+  }
+  return s, nil
+}
+
+// -----------------------------------------------------------------------------
+// Binary format
+//
+// All numbers are little endian.
+//
+//   magic     "GSCN"
+//   version   u16
+//   ncomp     u16                   number of component names
+//   names     ncomp × (u16 len, len bytes)
+//   nents     u32
+//   entities  nents × entity
+//
+//   entity:
+//     parent     i32                index of parent, or -1
+//     flags      u8                 1 = has transform, 2 = has name, 4 = has tags
+//     transform  10 × f32           position xyz, rotation xyzw, scale xyz (if flags&1)
+//     name       u16 len, len bytes (if flags&2)
+//     tags       u16 ntags, ntags × (u16 len, len bytes) (if flags&4)
+//     ncomp      u16
+//     components ncomp × (u16 name index, u32 len, len bytes)
+//
+
+const sceneMagic = "GSCN"
+
+type sceneWriter struct {
+  buf bytes.Buffer
+  tmp [8]byte
+}
+
+func (w *sceneWriter) u8(v uint8)   { w.buf.WriteByte(v) }
+func (w *sceneWriter) u16(v uint16) { binary.LittleEndian.PutUint16(w.tmp[:], v); w.buf.Write(w.tmp[:2]) }
+func (w *sceneWriter) u32(v uint32) { binary.LittleEndian.PutUint32(w.tmp[:], v); w.buf.Write(w.tmp[:4]) }
+func (w *sceneWriter) f32(v float32) { w.u32(math.Float32bits(v)) }
+func (w *sceneWriter) bytes(b []byte) { w.buf.Write(b) }
+func (w *sceneWriter) str(s string) { w.u16(uint16(len(s))); w.buf.WriteString(s) }
+
+func writeSceneBinary(out io.Writer, s *Scene) error {
+  // collect component names
+  var names []string
+  nameIndex := make(map[string]int)
+  for _, se := range s.Entities {
+    for name := range se.Components {
+      if _, ok := nameIndex[name]; !ok {
+        nameIndex[name] = len(names)
+        names = append(names, name)
+      }
+    }
+  }
+  if len(names) > math.MaxUint16 {
+    return errorf("too many component types")
+  }
+
+  w := &sceneWriter{}
+  w.bytes([]byte(sceneMagic))
+  w.u16(SceneVersion)
+  w.u16(uint16(len(names)))
+  for _, name := range names {
+    w.str(name)
+  }
+  w.u32(uint32(len(s.Entities)))
+  var vbuf bytes.Buffer
+  for _, se := range s.Entities {
+    w.u32(uint32(int32(se.Parent)))
+    var flags uint8
+    if se.Transform != nil {
+      flags |= 1
+    }
+    if se.Name != "" {
+      flags |= 2
+    }
+    if len(se.Tags) > 0 {
+      flags |= 4
+    }
+    w.u8(flags)
+    if t := se.Transform; t != nil {
+      for _, v := range t.Position { w.f32(v) }
+      for _, v := range t.Rotation.V { w.f32(v) }
+      w.f32(t.Rotation.W)
+      for _, v := range t.Scale { w.f32(v) }
+    }
+    if se.Name != "" {
+      w.str(se.Name)
+    }
+    if len(se.Tags) > 0 {
+      w.u16(uint16(len(se.Tags)))
+      for _, tag := range se.Tags {
+        w.str(tag)
+      }
+    }
+    w.u16(uint16(len(se.Components)))
+    // Note: iterate in names order (rather than map order) for deterministic output
+    for i, name := range names {
+      value, ok := se.Components[name]
+      if !ok {
+        continue
+      }
+      var data []byte
+      if m, ok := value.(encoding.BinaryMarshaler); ok {
+        var err error
+        if data, err = m.MarshalBinary(); err != nil {
+          return errorf("component %q: %v", name, err)
+        }
+      } else {
+        vbuf.Reset()
+        if err := binary.Write(&vbuf, binary.LittleEndian, value); err != nil {
+          return errorf("component %q: %v", name, err)
+        }
+        data = vbuf.Bytes()
+      }
+      w.u16(uint16(i))
+      w.u32(uint32(len(data)))
+      w.bytes(data)
+    }
+  }
+  _, err := out.Write(w.buf.Bytes())
+  return err
+}
+
+type sceneReader struct {
+  data []byte  // input not yet read
+  tmp  [8]byte // zeros, returned for numbers read after an error
+  err  error
+}
+
+// read returns the next n bytes of input. Since the whole input is in memory, lengths
+// read from a corrupt file are checked against what's left rather than allocated.
+func (r *sceneReader) read(n int) []byte {
+  if r.err == nil && (n < 0 || n > len(r.data)) {
+    r.err = io.ErrUnexpectedEOF
+  }
+  if r.err != nil {
+    if n <= len(r.tmp) {
+      return r.tmp[:n]
+    }
+    return nil
+  }
+  b := r.data[:n:n]
+  r.data = r.data[n:]
+  return b
+}
+
+func (r *sceneReader) u8() uint8    { return r.read(1)[0] }
+func (r *sceneReader) u16() uint16  { return binary.LittleEndian.Uint16(r.read(2)) }
+func (r *sceneReader) u32() uint32  { return binary.LittleEndian.Uint32(r.read(4)) }
+func (r *sceneReader) f32() float32 { return math.Float32frombits(r.u32()) }
+
+func (r *sceneReader) str() string { return string(r.read(int(r.u16()))) }
+
+func (w *World) readSceneBinary(in io.Reader) (*Scene, error) {
+  data, err := ioutil.ReadAll(in)
+  if err != nil {
+    return nil, err
+  }
+  r := &sceneReader{ data: data }
+  if string(r.read(len(sceneMagic))) != sceneMagic {
+    return nil, errorf("not a binary scene")
+  }
+  if version := r.u16(); r.err == nil && (version < 1 || version > SceneVersion) {
+    return nil, errorf("unsupported scene version %d", version)
+  }
+  names := make([]string, r.u16())
+  for i := range names {
+    names[i] = r.str()
+  }
+  nents := r.u32()
+  if r.err != nil {
+    return nil, r.err
+  }
+  s := &Scene{}
+  for i := 0; i < int(nents); i++ {
+    se := SceneEntity{ Parent: int(int32(r.u32())) }
+    flags := r.u8()
+    if flags & 1 != 0 {
+      t := &SceneTransform{}
+      for j := range t.Position { t.Position[j] = r.f32() }
+      for j := range t.Rotation.V { t.Rotation.V[j] = r.f32() }
+      t.Rotation.W = r.f32()
+      for j := range t.Scale { t.Scale[j] = r.f32() }
+      se.Transform = t
+    }
+    if flags & 2 != 0 {
+      se.Name = r.str()
+    }
+    if flags & 4 != 0 {
+      se.Tags = make([]string, r.u16())
+      for j := range se.Tags {
+        se.Tags[j] = r.str()
+      }
+    }
+    ncomp := int(r.u16())
+    for j := 0; j < ncomp && r.err == nil; j++ {
+      nameIndex := int(r.u16())
+      data := r.read(int(r.u32()))
+      if r.err != nil {
+        break
+      }
+      if nameIndex >= len(names) {
+        return nil, errorf("entity %d: invalid component name index %d", i, nameIndex)
+      }
+      value, err := w.decodeComponent(names[nameIndex], data, SceneBinary)
+      if err != nil {
+        return nil, err
+      }
+      if se.Components == nil {
+        se.Components = make(map[string]interface{}, ncomp)
+      }
+      se.Components[names[nameIndex]] = value
+    }
+    if r.err != nil {
+      return nil, r.err
+    }
+    s.Entities = append(s.Entities, se)
+    if err := s.validateParent(i); err != nil {
+      return nil, err
+    }
+  }
+  return s, nil
+}
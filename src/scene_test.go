@@ -0,0 +1,188 @@
+package main
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+// newSceneTestWorld returns a world with the movement component registered, like the
+// demo scene
+func newSceneTestWorld() (*World, *MovementSystem) {
+  w := newTestWorld()
+  movement := &MovementSystem{}
+  movement.Init(w)
+  w.AddSystem(movement)
+  w.RegisterComponent("movement", &movement.ComponentStore)
+  return w, movement
+}
+
+func populateSceneTestWorld(w *World, movement *MovementSystem) {
+  player, gun, monster := w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc()
+  w.Ents.Free(w.Ents.Alloc()) // so that Ents of a loaded world differ
+
+  tm := Matrix4Compose(Vec3{ 1, 2, 3 }, QuatRotate(0.5, Vec3{ 0, 1, 0 }), Vec3{ 1, 2, 1 })
+  w.CreateNode(player, tm)
+  w.CreateNode(gun, Matrix4Identity)
+  w.AppendChild(player, gun)
+  w.Names.SetName(player, "player")
+  w.Names.SetName(gun, "gun")
+  movement.Assoc(player, MovementData{ mass: 1, velocity: Vec2{ 0.5, -1 } })
+
+  w.Names.SetName(monster, "monster")
+  w.Tags.AddTags(monster, "enemy", "big")
+  movement.Assoc(monster, MovementData{ mass: 1.2, position: Vec2{ 10, 10 } })
+
+  camera := w.Ents.Alloc()
+  w.CreateNode(camera, Matrix4Identity)
+  w.Cameras.Assoc(camera, OrthographicCamera(4, -1, 1))
+}
+
+func TestSceneRoundTrip(t *testing.T) {
+  for _, format := range []SceneFormat{ SceneJSON, SceneBinary } {
+    w1, movement1 := newSceneTestWorld()
+    populateSceneTestWorld(w1, movement1)
+    var buf bytes.Buffer
+    if err := w1.SaveScene(&buf, format); err != nil {
+      t.Fatalf("format %d: SaveScene: %v", format, err)
+    }
+    data := append([]byte(nil), buf.Bytes()...)
+
+    w2, movement2 := newSceneTestWorld()
+    ents, err := w2.LoadScene(bytes.NewReader(data))
+    if err != nil {
+      t.Fatalf("format %d: LoadScene: %v", format, err)
+    }
+    if len(ents) != 4 {
+      t.Fatalf("format %d: loaded %d entities, expected 4", format, len(ents))
+    }
+
+    // entities are remapped; the hierarchy and components must be the same
+    gun := w2.Names.FindPath("player/gun")
+    if gun == NilEnt {
+      t.Fatalf("format %d: player/gun not found after load", format)
+    }
+    monster := w2.Names.Find("monster")
+    if !w2.Tags.Has(monster, "enemy", "big") {
+      t.Errorf("format %d: monster lost its tags", format)
+    }
+    if m := movement2.Get(monster); m == nil || m.position != (Vec2{ 10, 10 }) || m.mass != 1.2 {
+      t.Errorf("format %d: monster movement %v", format, m)
+    }
+    if w2.Cameras.Len() != 1 || w2.Cameras.data[0] != OrthographicCamera(4, -1, 1) {
+      t.Errorf("format %d: camera not restored", format)
+    }
+
+    // saving the loaded world gives the same file
+    buf.Reset()
+    if err := w2.SaveScene(&buf, format); err != nil {
+      t.Fatalf("format %d: SaveScene after load: %v", format, err)
+    }
+    if !bytes.Equal(buf.Bytes(), data) {
+      t.Errorf("format %d: scene differs after round trip:\n%s\n---\n%s",
+        format, data, buf.Bytes())
+    }
+    if s1, s2 := w1.CaptureScene(), w2.CaptureScene(); !reflect.DeepEqual(s1, s2) {
+      t.Errorf("format %d: captured scenes differ:\n%+v\n%+v", format, s1, s2)
+    }
+  }
+}
+
+func TestSceneJSONTransformDefaults(t *testing.T) {
+  w, _ := newSceneTestWorld()
+  s, err := w.ReadScene(strings.NewReader(
+    `{"version":2,"entities":[{"transform":{"position":[1,2,3]}}]}`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  tf := s.Entities[0].Transform
+  if tf.Scale != (Vec3{ 1, 1, 1 }) || tf.Rotation != QuatIdentity {
+    t.Errorf("missing scale and rotation decoded as %v, %v", tf.Scale, tf.Rotation)
+  }
+}
+
+// Entities freed with Ents.Free instead of World.Destroy are still linked into the
+// transform tree; they are left out of captured scenes together with their descendants
+func TestSceneCaptureFreedChild(t *testing.T) {
+  w, _ := newSceneTestWorld()
+  root, freed, grandchild, sibling := w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc()
+  for _, e := range []Ent{ root, freed, grandchild, sibling } {
+    w.CreateNode(e, Matrix4Identity)
+  }
+  w.Names.SetName(root, "root")
+  w.Names.SetName(sibling, "sibling")
+  w.AppendChild(root, freed)
+  w.AppendChild(root, sibling)
+  w.AppendChild(freed, grandchild)
+  w.Ents.Free(freed)
+
+  for _, s := range []*Scene{ w.CaptureScene(), w.CaptureScene(root) } {
+    var names []string
+    for _, e := range s.Entities {
+      names = append(names, e.Name)
+    }
+    if strings.Join(names, ",") != "root,sibling" {
+      t.Errorf("captured entities %q, expected root and sibling", names)
+    }
+  }
+  var buf bytes.Buffer
+  if err := w.SaveScene(&buf, SceneJSON); err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestSceneBinaryCorrupt(t *testing.T) {
+  w1, movement1 := newSceneTestWorld()
+  populateSceneTestWorld(w1, movement1)
+  var buf bytes.Buffer
+  if err := w1.SaveScene(&buf, SceneBinary); err != nil {
+    t.Fatal(err)
+  }
+  data := buf.Bytes()
+
+  // every truncation is an error
+  w2, _ := newSceneTestWorld()
+  for n := len(sceneMagic); n < len(data); n++ {
+    if _, err := w2.ReadScene(bytes.NewReader(data[:n])); err == nil {
+      t.Fatalf("no error for scene truncated to %d of %d bytes", n, len(data))
+    }
+  }
+
+  // a component length larger than the file is an error rather than an allocation.
+  // The file ends with the monster's MovementData: u32 length followed by 28 bytes.
+  i := len(data) - 28 - 4
+  if binary.LittleEndian.Uint32(data[i:]) != 28 {
+    t.Fatal("unexpected end of file; expected MovementData")
+  }
+  bad := append([]byte(nil), data...)
+  binary.LittleEndian.PutUint32(bad[i:], 0xffffffff)
+  if _, err := w2.ReadScene(bytes.NewReader(bad)); err == nil {
+    t.Error("no error for oversized component length")
+  }
+}
+
+func TestSpawnSceneErrors(t *testing.T) {
+  w, _ := newSceneTestWorld()
+  manyTags := make([]string, MaxTags + 1)
+  for i := range manyTags {
+    manyTags[i] = fmt.Sprintf("tag%d", i)
+  }
+  for _, s := range []*Scene{
+    { Entities: []SceneEntity{ { Parent: 0 } } },
+    { Entities: []SceneEntity{ { Parent: -1 }, { Parent: 5 } } },
+    { Entities: []SceneEntity{ { Parent: -1, Components: map[string]interface{}{ "nope": 1 } } } },
+    { Entities: []SceneEntity{ { Parent: -1, Components: map[string]interface{}{ "movement": 1 } } } },
+    { Entities: []SceneEntity{ { Parent: -1, Name: "a/b" } } },
+    { Entities: []SceneEntity{ { Parent: -1, Tags: manyTags } } },
+  } {
+    if _, err := w.SpawnScene(s, NilEnt); err == nil {
+      t.Errorf("no error for %+v", s.Entities)
+    }
+  }
+  if n := len(w.Ents.generation) - 1; n != 0 {
+    t.Errorf("failed SpawnScene allocated %d entities", n)
+  }
+}
@@ -0,0 +1,799 @@
+package main
+
+import (
+  "bytes"
+  "encoding/binary"
+  "image"
+  "image/color"
+  "image/png"
+  "io"
+  "io/ioutil"
+  "math"
+  "os"
+)
+
+// SoftwareDevice is a GraphicsDevice that renders on the CPU into an RGBA image.
+// It makes it possible to check that frames render correctly without a browser or
+// GPU, for instance by comparing them to golden images (see CheckGoldenPNG.)
+//
+// It implements the subset of GL used by the renderer: vertex and index buffers,
+// drawArrays and drawElements of triangles, depth test, blending, face culling, 2D
+// textures and clear. GLSL can't be run, so programs are Go functions registered with
+// RegisterSoftShader for the GLSL source they mirror.
+//
+// Limitations: triangles are not clipped but discarded if a vertex is behind the
+// camera (w <= 0) and fragments outside the depth range are discarded. Attributes must
+// be GL_FLOAT and indices GL_UNSIGNED_SHORT. Textures are not mipmapped; they are
+// sampled with their GL_TEXTURE_MAG_FILTER.
+//
+// Example:
+//
+//   dev := NewSoftwareDevice()
+//   r := NewRenderer(dev, 320, 240, 1)
+//   ...
+//   r.render(0)
+//   err := CheckGoldenPNG(dev.Image(), "testdata/cube.png", 2)
+//
+type SoftwareDevice struct {
+  color *image.RGBA // color buffer; row 0 is the top row (GL's is the bottom)
+  depth []float32   // depth buffer; row 0 is the bottom row
+
+  viewportRect  [4]int32
+  clearColorV   Vec4
+  clearDepthV   float32
+  depthTest     bool
+  depthFuncV    uint32
+  depthWrite    bool
+  blend         bool
+  blendFuncV    [2]uint32
+  cull          bool
+  cullFaceV     uint32
+
+  buffers       map[uintptr]*softBuffer
+  arrayBuffer   uintptr
+  elementBuffer uintptr
+  attribs       []softAttrib // indexed by location
+  programs      map[uintptr]*softProgram
+  uniforms      map[GLUniform]softUniformRef
+  program       *softProgram // current program
+  textures      map[uintptr]*softTexture
+  activeTex     uint32             // e.g. GL_TEXTURE0
+  boundTextures map[uint32]uintptr // texture bound to GL_TEXTURE_2D, by texture unit
+}
+
+type softBuffer struct {
+  data []byte // little endian
+}
+
+type softAttrib struct {
+  enabled bool
+  buffer  uintptr
+  size    uint32 // number of components
+  stride  uint32 // in bytes; 0 = tightly packed
+  offset  uint32 // in bytes
+}
+
+type softTexture struct {
+  width, height int
+  pix           []uint8 // RGBA; row 0 is at t=0
+  params        map[uint32]int32
+}
+
+type softProgram struct {
+  shader   *SoftShader
+  uniforms SoftUniforms
+}
+
+type softUniformRef struct {
+  program uintptr
+  name    string
+}
+
+// SoftShader is a Go implementation of a GLSL program, run by SoftwareDevice
+type SoftShader struct {
+  Attribs  []string // attribute names, in location order
+  Uniforms []string // names of uniforms declared by the program
+  Varyings int      // number of floats passed from Vertex to Fragment
+
+  // Vertex is the vertex shader. It returns gl_Position and sets varyings.
+  // attribs holds the vertex's attributes in location order, expanded to vec4 like GL
+  // does (missing components are 0, 0, 0, 1.)
+  Vertex func(u SoftUniforms, attribs []Vec4, varyings []float32) Vec4
+
+  // Fragment is the fragment shader. It returns gl_FragColor. varyings are
+  // interpolated (perspective-correct) from the triangle's vertices.
+  Fragment func(u SoftUniforms, fragCoord Vec4, varyings []float32) Vec4
+}
+
+// SoftUniforms gives a SoftShader access to uniform values by name and to textures.
+// Uniforms that haven't been set are 0.
+type SoftUniforms struct {
+  values map[string][]float32
+  dev    *SoftwareDevice
+}
+
+func (u SoftUniforms) Float(name string) float32 {
+  if v := u.values[name]; len(v) > 0 {
+    return v[0]
+  }
+  return 0
+}
+
+func (u SoftUniforms) Vec2(name string) (v Vec2) {
+  copy(v[:], u.values[name])
+  return
+}
+
+func (u SoftUniforms) Vec3(name string) (v Vec3) {
+  copy(v[:], u.values[name])
+  return
+}
+
+func (u SoftUniforms) Vec4(name string) (v Vec4) {
+  copy(v[:], u.values[name])
+  return
+}
+
+func (u SoftUniforms) Matrix4(name string) (m Matrix4) {
+  copy(m[:], u.values[name])
+  return
+}
+
+// Texture2D samples the texture of sampler uniform name at st, like GLSL's texture2D.
+// Without a texture, the result is opaque black.
+func (u SoftUniforms) Texture2D(name string, st Vec2) Vec4 {
+  unit := GL_TEXTURE0 + uint32(u.Float(name))
+  t := u.dev.textures[u.dev.boundTextures[unit]]
+  if t == nil || t.width == 0 || t.height == 0 {
+    return Vec4{ 0, 0, 0, 1 }
+  }
+  return t.sample(st)
+}
+
+// softShaders maps GLSL source (vertex and fragment) to Go implementations
+var softShaders = make(map[[2]string]*SoftShader)
+
+// RegisterSoftShader registers s as the implementation of the program with the
+// given GLSL source, to be used by SoftwareDevice.createProgram
+func RegisterSoftShader(vertexSource, fragmentSource string, s *SoftShader) {
+  softShaders[[2]string{ vertexSource, fragmentSource }] = s
+}
+
+
+func NewSoftwareDevice() *SoftwareDevice {
+  d := &SoftwareDevice{
+    color:         image.NewRGBA(image.Rect(0, 0, 0, 0)),
+    depthFuncV:    GL_LESS,
+    depthWrite:    true,
+    clearDepthV:   1,
+    buffers:       make(map[uintptr]*softBuffer),
+    programs:      make(map[uintptr]*softProgram),
+    uniforms:      make(map[GLUniform]softUniformRef),
+    textures:      make(map[uintptr]*softTexture),
+    activeTex:     GL_TEXTURE0,
+    boundTextures: make(map[uint32]uintptr),
+    blendFuncV:    [2]uint32{ GL_ONE, GL_ZERO },
+    cullFaceV:     GL_BACK,
+  }
+  return d
+}
+
+// Image returns the color buffer. The image is reused by later draws.
+func (d *SoftwareDevice) Image() *image.RGBA {
+  return d.color
+}
+
+// WritePNG writes the color buffer to w as a PNG image
+func (d *SoftwareDevice) WritePNG(w io.Writer) error {
+  return png.Encode(w, d.color)
+}
+
+// --------------------------------------------------------------------------------------
+// GraphicsDevice implementation
+
+func (d *SoftwareDevice) drawingBufferSize() (width, height uint32) {
+  size := d.color.Rect.Size()
+  return uint32(size.X), uint32(size.Y)
+}
+
+func (d *SoftwareDevice) setCanvasSize(width, height uint32, pixelRatio float32) {
+  width  = uint32(float32(width) * pixelRatio)
+  height = uint32(float32(height) * pixelRatio)
+  d.color = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+  d.depth = make([]float32, width * height)
+  d.viewportRect = [4]int32{ 0, 0, int32(width), int32(height) }
+}
+
+func (d *SoftwareDevice) viewport(x, y int32, width, height uint32) {
+  d.viewportRect = [4]int32{ x, y, int32(width), int32(height) }
+}
+
+func (d *SoftwareDevice) clearColor(r, g, b, a float32) { d.clearColorV = Vec4{ r, g, b, a } }
+func (d *SoftwareDevice) clearDepth(depth float32)      { d.clearDepthV = depth }
+func (d *SoftwareDevice) depthFunc(funcid uint32)       { d.depthFuncV = funcid }
+func (d *SoftwareDevice) depthMask(write bool)          { d.depthWrite = write }
+func (d *SoftwareDevice) cullFace(mode uint32)          { d.cullFaceV = mode }
+
+func (d *SoftwareDevice) blendFunc(sfactor, dfactor uint32) {
+  d.blendFuncV = [2]uint32{ sfactor, dfactor }
+}
+
+func (d *SoftwareDevice) enable(cap uint32)  { d.setCap(cap, true) }
+func (d *SoftwareDevice) disable(cap uint32) { d.setCap(cap, false) }
+
+func (d *SoftwareDevice) setCap(cap uint32, enabled bool) {
+  switch cap {
+  case GL_DEPTH_TEST: d.depthTest = enabled
+  case GL_BLEND:      d.blend = enabled
+  case GL_CULL_FACE:  d.cull = enabled
+  }
+}
+
+func (d *SoftwareDevice) clear(mask uint32) {
+  if mask & GL_COLOR_BUFFER_BIT != 0 {
+    c := softColor(d.clearColorV)
+    pix := d.color.Pix
+    for i := 0; i < len(pix); i += 4 {
+      pix[i], pix[i+1], pix[i+2], pix[i+3] = c.R, c.G, c.B, c.A
+    }
+  }
+  if mask & GL_DEPTH_BUFFER_BIT != 0 {
+    for i := range d.depth {
+      d.depth[i] = d.clearDepthV
+    }
+  }
+}
+
+func (d *SoftwareDevice) createBuffer() GLBuffer {
+  b := GLBuffer{ id: glGenID() }
+  d.buffers[b.id] = &softBuffer{}
+  return b
+}
+
+func (d *SoftwareDevice) deleteBuffer(b GLBuffer) {
+  delete(d.buffers, b.id)
+}
+
+func (d *SoftwareDevice) bindBuffer(target uint32, buffer GLBuffer) {
+  switch target {
+  case GL_ARRAY_BUFFER:         d.arrayBuffer = buffer.id
+  case GL_ELEMENT_ARRAY_BUFFER: d.elementBuffer = buffer.id
+  default:
+    panicf("SoftwareDevice.bindBuffer: unsupported target %d", target)
+  }
+}
+
+func (d *SoftwareDevice) bound(target uint32) *softBuffer {
+  id := d.arrayBuffer
+  if target == GL_ELEMENT_ARRAY_BUFFER {
+    id = d.elementBuffer
+  }
+  b := d.buffers[id]
+  if b == nil {
+    panicf("SoftwareDevice: no buffer bound to target %d", target)
+  }
+  return b
+}
+
+func (d *SoftwareDevice) bufferDataF32(target uint32, data []float32, usage uint32) {
+  b := d.bound(target)
+  b.data = make([]byte, len(data) * 4)
+  for i, v := range data {
+    putf32(b.data[i*4:], v)
+  }
+}
+
+func (d *SoftwareDevice) bufferDataU16(target uint32, data []uint16, usage uint32) {
+  b := d.bound(target)
+  b.data = make([]byte, len(data) * 2)
+  for i, v := range data {
+    b.data[i*2], b.data[i*2+1] = byte(v), byte(v >> 8)
+  }
+}
+
+func (d *SoftwareDevice) createTexture() GLTexture {
+  t := GLTexture{ id: glGenID() }
+  d.textures[t.id] = &softTexture{ params: make(map[uint32]int32) }
+  return t
+}
+
+func (d *SoftwareDevice) deleteTexture(t GLTexture) {
+  delete(d.textures, t.id)
+}
+
+func (d *SoftwareDevice) activeTexture(texture uint32) {
+  d.activeTex = texture
+}
+
+func (d *SoftwareDevice) bindTexture(target uint32, t GLTexture) {
+  if target != GL_TEXTURE_2D {
+    panicf("SoftwareDevice.bindTexture: unsupported target %d", target)
+  }
+  d.boundTextures[d.activeTex] = t.id
+}
+
+func (d *SoftwareDevice) boundTexture(target uint32) *softTexture {
+  t := d.textures[d.boundTextures[d.activeTex]]
+  if target != GL_TEXTURE_2D || t == nil {
+    panicf("SoftwareDevice: no texture bound to target %d", target)
+  }
+  return t
+}
+
+func (d *SoftwareDevice) texParameteri(target, pname uint32, param int32) {
+  d.boundTexture(target).params[pname] = param
+}
+
+func (d *SoftwareDevice) texImage2D(target, width, height uint32, pixels []uint8) {
+  if len(pixels) != int(width * height * 4) {
+    panicf("SoftwareDevice.texImage2D: %d bytes of pixels for %dx%d", len(pixels), width, height)
+  }
+  t := d.boundTexture(target)
+  t.width, t.height = int(width), int(height)
+  t.pix = append([]uint8(nil), pixels...)
+}
+
+func (d *SoftwareDevice) createProgram(vSource, fSource string) (*GLProgram, error) {
+  s := softShaders[[2]string{ vSource, fSource }]
+  if s == nil {
+    return nil, errorf("no SoftShader registered for program (see RegisterSoftShader)")
+  }
+  p := &GLProgram{ id: glGenID(), dev: d }
+  d.programs[p.id] = &softProgram{
+    shader:   s,
+    uniforms: SoftUniforms{ values: make(map[string][]float32), dev: d },
+  }
+  return p, nil
+}
+
+func (d *SoftwareDevice) softProgram(p *GLProgram) *softProgram {
+  sp := d.programs[p.id]
+  if sp == nil {
+    panicf("SoftwareDevice: program %d was not created on this device", p.id)
+  }
+  return sp
+}
+
+func (d *SoftwareDevice) uniformLocation(p *GLProgram, name string) (GLUniform, error) {
+  for _, n := range d.softProgram(p).shader.Uniforms {
+    if n == name {
+      u := GLUniform(glGenID())
+      d.uniforms[u] = softUniformRef{ program: p.id, name: name }
+      return u, nil
+    }
+  }
+  return 0, errorf("uniform %#v not found", name)
+}
+
+func (d *SoftwareDevice) attribLocation(p *GLProgram, name string) (uint32, error) {
+  for i, n := range d.softProgram(p).shader.Attribs {
+    if n == name {
+      return uint32(i), nil
+    }
+  }
+  return 0, errorf("attribute %#v not found", name)
+}
+
+func (d *SoftwareDevice) useProgram(p *GLProgram) bool {
+  sp := d.softProgram(p)
+  if d.program == sp {
+    return false
+  }
+  d.program = sp
+  return true
+}
+
+// setUniform sets a uniform of the current program, like glUniform* does
+func (d *SoftwareDevice) setUniform(location GLUniform, values []float32) {
+  ref, ok := d.uniforms[location]
+  if !ok {
+    panicf("SoftwareDevice: unknown uniform location %d", location)
+  }
+  if d.program == nil || d.programs[ref.program] != d.program {
+    panicf("SoftwareDevice: uniform %q does not belong to the current program", ref.name)
+  }
+  u := d.program.uniforms.values
+  u[ref.name] = append(u[ref.name][:0], values...)
+}
+
+func (d *SoftwareDevice) uniformMatrix4fv(location GLUniform, transpose bool, value [16]float32) {
+  if transpose {
+    panicf("SoftwareDevice.uniformMatrix4fv: transpose is not supported")
+  }
+  d.setUniform(location, value[:])
+}
+
+func (d *SoftwareDevice) uniformf(location GLUniform, value ...float32) {
+  d.setUniform(location, value)
+}
+
+func (d *SoftwareDevice) uniformi(location GLUniform, value ...int32) {
+  v := make([]float32, len(value))
+  for i, x := range value {
+    v[i] = float32(x)
+  }
+  d.setUniform(location, v)
+}
+
+func (d *SoftwareDevice) attrib(index uint32) *softAttrib {
+  for int(index) >= len(d.attribs) {
+    d.attribs = append(d.attribs, softAttrib{})
+  }
+  return &d.attribs[index]
+}
+
+func (d *SoftwareDevice) vertexAttribPointer(
+  index, size uint32, typ GLenum, normalized bool, stride, offset uint32) {
+  if typ != GL_FLOAT {
+    panicf("SoftwareDevice.vertexAttribPointer: unsupported type %d", typ)
+  }
+  a := d.attrib(index)
+  a.buffer = d.arrayBuffer
+  a.size = size
+  a.stride = stride
+  a.offset = offset
+}
+
+func (d *SoftwareDevice) enableVertexAttribArray(index uint32)  { d.attrib(index).enabled = true }
+func (d *SoftwareDevice) disableVertexAttribArray(index uint32) { d.attrib(index).enabled = false }
+
+func (d *SoftwareDevice) flush() {}
+
+func (d *SoftwareDevice) drawArrays(mode, first, count uint32) {
+  d.draw(mode, count, func(i uint32) uint32 { return first + i })
+}
+
+func (d *SoftwareDevice) drawElements(mode, count, kind, offset uint32) {
+  if kind != GL_UNSIGNED_SHORT {
+    panicf("SoftwareDevice.drawElements: unsupported index type %d", kind)
+  }
+  data := d.bound(GL_ELEMENT_ARRAY_BUFFER).data
+  d.draw(mode, count, func(i uint32) uint32 {
+    p := offset + i*2
+    return uint32(data[p]) | uint32(data[p+1]) << 8
+  })
+}
+
+// --------------------------------------------------------------------------------------
+// rasterizer
+
+// softVertex is a vertex processed by the vertex shader
+type softVertex struct {
+  x, y, z  float32   // window coordinates
+  invW     float32   // 1/w of the clip position
+  varyings []float32
+  culled   bool      // true if the vertex is behind the camera
+}
+
+// draw assembles count vertices into triangles according to mode. vertex maps the
+// i:th vertex of the draw call to its vertex index.
+func (d *SoftwareDevice) draw(mode, count uint32, vertex func(i uint32) uint32) {
+  if d.program == nil {
+    panicf("SoftwareDevice: draw without a program")
+  }
+  cache := make(map[uint32]*softVertex)
+  v := func(i uint32) *softVertex {
+    index := vertex(i)
+    sv := cache[index]
+    if sv == nil {
+      sv = d.processVertex(index)
+      cache[index] = sv
+    }
+    return sv
+  }
+  switch mode {
+  case GL_TRIANGLES:
+    for i := uint32(0); i + 2 < count; i += 3 {
+      d.rasterize(v(i), v(i+1), v(i+2))
+    }
+  case GL_TRIANGLE_STRIP:
+    for i := uint32(0); i + 2 < count; i++ {
+      d.rasterize(v(i), v(i+1), v(i+2))
+    }
+  case GL_TRIANGLE_FAN:
+    for i := uint32(1); i + 1 < count; i++ {
+      d.rasterize(v(0), v(i), v(i+1))
+    }
+  default:
+    panicf("SoftwareDevice: unsupported primitive mode %d", mode)
+  }
+}
+
+// processVertex fetches the attributes of a vertex and runs the vertex shader
+func (d *SoftwareDevice) processVertex(index uint32) *softVertex {
+  s := d.program.shader
+  attribs := make([]Vec4, len(s.Attribs))
+  for loc := range attribs {
+    attribs[loc] = Vec4{ 0, 0, 0, 1 }
+    if loc >= len(d.attribs) || !d.attribs[loc].enabled {
+      continue
+    }
+    a := &d.attribs[loc]
+    stride := a.stride
+    if stride == 0 {
+      stride = a.size * 4
+    }
+    data := d.buffers[a.buffer].data
+    p := a.offset + index * stride
+    for c := uint32(0); c < a.size && c < 4; c++ {
+      attribs[loc][c] = getf32(data[p + c*4:])
+    }
+  }
+  sv := &softVertex{ varyings: make([]float32, s.Varyings) }
+  pos := s.Vertex(d.program.uniforms, attribs, sv.varyings)
+  if pos[3] <= 0 {
+    sv.culled = true
+    return sv
+  }
+  // perspective divide and viewport transform
+  vp := d.viewportRect
+  sv.invW = 1 / pos[3]
+  sv.x = (pos[0] * sv.invW * 0.5 + 0.5) * float32(vp[2]) + float32(vp[0])
+  sv.y = (pos[1] * sv.invW * 0.5 + 0.5) * float32(vp[3]) + float32(vp[1])
+  sv.z = pos[2] * sv.invW * 0.5 + 0.5
+  return sv
+}
+
+func edge(ax, ay, bx, by, px, py float32) float32 {
+  return (bx - ax) * (py - ay) - (by - ay) * (px - ax)
+}
+
+func (d *SoftwareDevice) rasterize(v0, v1, v2 *softVertex) {
+  if v0.culled || v1.culled || v2.culled {
+    return
+  }
+  area := edge(v0.x, v0.y, v1.x, v1.y, v2.x, v2.y)
+  if area == 0 {
+    return
+  }
+  if d.cull {
+    front := area > 0 // counter-clockwise in window coordinates
+    switch d.cullFaceV {
+    case GL_BACK:           if !front { return }
+    case GL_FRONT:          if front { return }
+    case GL_FRONT_AND_BACK: return
+    }
+  }
+
+  // bounding box, clipped to the viewport and the drawing buffer
+  vp := d.viewportRect
+  size := d.color.Rect.Size()
+  minx := maxi32(int32(min3f(v0.x, v1.x, v2.x)), maxi32(vp[0], 0))
+  miny := maxi32(int32(min3f(v0.y, v1.y, v2.y)), maxi32(vp[1], 0))
+  maxx := mini32(int32(max3f(v0.x, v1.x, v2.x)) + 1, mini32(vp[0] + vp[2], int32(size.X)))
+  maxy := mini32(int32(max3f(v0.y, v1.y, v2.y)) + 1, mini32(vp[1] + vp[3], int32(size.Y)))
+
+  s := d.program.shader
+  varyings := make([]float32, s.Varyings)
+  for y := miny; y < maxy; y++ {
+    for x := minx; x < maxx; x++ {
+      px, py := float32(x) + 0.5, float32(y) + 0.5
+      w0 := edge(v1.x, v1.y, v2.x, v2.y, px, py) / area
+      w1 := edge(v2.x, v2.y, v0.x, v0.y, px, py) / area
+      w2 := edge(v0.x, v0.y, v1.x, v1.y, px, py) / area
+      if w0 < 0 || w1 < 0 || w2 < 0 {
+        continue
+      }
+      z := w0*v0.z + w1*v1.z + w2*v2.z
+      if z < 0 || z > 1 {
+        continue
+      }
+      di := int(y) * size.X + int(x)
+      if d.depthTest && !d.depthPass(z, d.depth[di]) {
+        continue
+      }
+
+      // perspective-correct interpolation of varyings
+      p0, p1, p2 := w0*v0.invW, w1*v1.invW, w2*v2.invW
+      invW := p0 + p1 + p2
+      for i := range varyings {
+        varyings[i] = (p0*v0.varyings[i] + p1*v1.varyings[i] + p2*v2.varyings[i]) / invW
+      }
+      fragCoord := Vec4{ px, py, z, invW }
+      c := s.Fragment(d.program.uniforms, fragCoord, varyings)
+      if d.blend {
+        c = d.blendColor(c, d.color.RGBAAt(int(x), size.Y - 1 - int(y)))
+      }
+
+      d.color.SetRGBA(int(x), size.Y - 1 - int(y), softColor(c))
+      if d.depthTest && d.depthWrite {
+        d.depth[di] = z
+      }
+    }
+  }
+}
+
+func (d *SoftwareDevice) depthPass(z, stored float32) bool {
+  switch d.depthFuncV {
+  case GL_NEVER:    return false
+  case GL_LESS:     return z < stored
+  case GL_EQUAL:    return z == stored
+  case GL_LEQUAL:   return z <= stored
+  case GL_GREATER:  return z > stored
+  case GL_NOTEQUAL: return z != stored
+  case GL_GEQUAL:   return z >= stored
+  }
+  return true // GL_ALWAYS
+}
+
+// blendColor blends a fragment's color src with the color in the buffer, dst,
+// according to blendFunc
+func (d *SoftwareDevice) blendColor(src Vec4, dst color.RGBA) Vec4 {
+  for i := range src {
+    src[i] = float32(math.Max(0, math.Min(1, float64(src[i]))))
+  }
+  dstv := Vec4{
+    float32(dst.R) / 255, float32(dst.G) / 255, float32(dst.B) / 255, float32(dst.A) / 255,
+  }
+  sf := softBlendFactor(d.blendFuncV[0], src, dstv)
+  df := softBlendFactor(d.blendFuncV[1], src, dstv)
+  var c Vec4
+  for i := range c {
+    c[i] = src[i] * sf[i] + dstv[i] * df[i]
+  }
+  return c
+}
+
+func softBlendFactor(factor uint32, src, dst Vec4) Vec4 {
+  one := Vec4{ 1, 1, 1, 1 }
+  switch factor {
+  case GL_ZERO:                return Vec4{}
+  case GL_ONE:                 return one
+  case GL_SRC_COLOR:           return src
+  case GL_ONE_MINUS_SRC_COLOR: return Vec4{ 1 - src[0], 1 - src[1], 1 - src[2], 1 - src[3] }
+  case GL_DST_COLOR:           return dst
+  case GL_ONE_MINUS_DST_COLOR: return Vec4{ 1 - dst[0], 1 - dst[1], 1 - dst[2], 1 - dst[3] }
+  case GL_SRC_ALPHA:           return Vec4{ src[3], src[3], src[3], src[3] }
+  case GL_DST_ALPHA:           return Vec4{ dst[3], dst[3], dst[3], dst[3] }
+  case GL_ONE_MINUS_SRC_ALPHA:
+    a := 1 - src[3]
+    return Vec4{ a, a, a, a }
+  case GL_ONE_MINUS_DST_ALPHA:
+    a := 1 - dst[3]
+    return Vec4{ a, a, a, a }
+  }
+  panicf("SoftwareDevice: unsupported blend factor %d", factor)
+  return one
+}
+
+// sample returns the color of t at st, filtered and wrapped according to t's params
+func (t *softTexture) sample(st Vec2) Vec4 {
+  x := st[0] * float32(t.width) - 0.5
+  y := st[1] * float32(t.height) - 0.5
+  if t.params[GL_TEXTURE_MAG_FILTER] == int32(GL_NEAREST) {
+    return t.texel(int(math.Floor(float64(x + 0.5))), int(math.Floor(float64(y + 0.5))))
+  }
+  x0, y0 := math.Floor(float64(x)), math.Floor(float64(y))
+  fx, fy := x - float32(x0), y - float32(y0)
+  ix, iy := int(x0), int(y0)
+  var c Vec4
+  for i, w := range [4]float32{ (1 - fx) * (1 - fy), fx * (1 - fy), (1 - fx) * fy, fx * fy } {
+    texel := t.texel(ix + i % 2, iy + i / 2)
+    for j := range c {
+      c[j] += texel[j] * w
+    }
+  }
+  return c
+}
+
+// texel returns the color of the texel at x,y, wrapped according to t's params
+func (t *softTexture) texel(x, y int) Vec4 {
+  wrap := func(v, size int, mode int32) int {
+    if mode == int32(GL_CLAMP_TO_EDGE) {
+      if v < 0 {
+        return 0
+      }
+      if v >= size {
+        return size - 1
+      }
+      return v
+    }
+    // GL_REPEAT (the default)
+    v %= size
+    if v < 0 {
+      v += size
+    }
+    return v
+  }
+  x = wrap(x, t.width, t.params[GL_TEXTURE_WRAP_S])
+  y = wrap(y, t.height, t.params[GL_TEXTURE_WRAP_T])
+  p := t.pix[(y * t.width + x) * 4:]
+  return Vec4{
+    float32(p[0]) / 255, float32(p[1]) / 255, float32(p[2]) / 255, float32(p[3]) / 255,
+  }
+}
+
+func softColor(c Vec4) color.RGBA {
+  b := func(v float32) uint8 {
+    if v <= 0 {
+      return 0
+    }
+    if v >= 1 {
+      return 255
+    }
+    return uint8(v * 255 + 0.5)
+  }
+  return color.RGBA{ b(c[0]), b(c[1]), b(c[2]), b(c[3]) }
+}
+
+func min3f(a, b, c float32) float32 {
+  if b < a { a = b }
+  if c < a { a = c }
+  return a
+}
+
+func max3f(a, b, c float32) float32 {
+  if b > a { a = b }
+  if c > a { a = c }
+  return a
+}
+
+func putf32(b []byte, v float32) { binary.LittleEndian.PutUint32(b, math.Float32bits(v)) }
+func getf32(b []byte) float32    { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }
+
+func mini32(a, b int32) int32 { if a < b { return a }; return b }
+func maxi32(a, b int32) int32 { if a > b { return a }; return b }
+
+// --------------------------------------------------------------------------------------
+// golden images
+
+// CompareImages returns the number of pixels of a and b that differ by more than
+// tolerance in any channel. Returns an error if the images have different sizes.
+func CompareImages(a, b image.Image, tolerance uint8) (int, error) {
+  if a.Bounds().Size() != b.Bounds().Size() {
+    return 0, errorf("image size %v != %v", a.Bounds().Size(), b.Bounds().Size())
+  }
+  diff := func(x, y uint32) bool {
+    x, y = x >> 8, y >> 8
+    if x > y {
+      return x - y > uint32(tolerance)
+    }
+    return y - x > uint32(tolerance)
+  }
+  n := 0
+  ab, bb := a.Bounds(), b.Bounds()
+  for y := 0; y < ab.Dy(); y++ {
+    for x := 0; x < ab.Dx(); x++ {
+      r1, g1, b1, a1 := a.At(ab.Min.X + x, ab.Min.Y + y).RGBA()
+      r2, g2, b2, a2 := b.At(bb.Min.X + x, bb.Min.Y + y).RGBA()
+      if diff(r1, r2) || diff(g1, g2) || diff(b1, b2) || diff(a1, a2) {
+        n++
+      }
+    }
+  }
+  return n, nil
+}
+
+// CheckGoldenPNG compares img with the PNG image in filename (see CompareImages.)
+// If the environment variable UPDATE_GOLDEN is set, img is written to filename
+// instead. A missing file is an error, so that a golden that wasn't committed fails
+// tests rather than being silently created.
+func CheckGoldenPNG(img image.Image, filename string, tolerance uint8) error {
+  if os.Getenv("UPDATE_GOLDEN") != "" {
+    var b bytes.Buffer
+    if err := png.Encode(&b, img); err != nil {
+      return err
+    }
+    return ioutil.WriteFile(filename, b.Bytes(), 0644)
+  }
+  data, err := ioutil.ReadFile(filename)
+  if os.IsNotExist(err) {
+    return errorf("%s: golden image missing; run with UPDATE_GOLDEN=1 to create it",
+      filename)
+  }
+  if err != nil {
+    return err
+  }
+  golden, err := png.Decode(bytes.NewReader(data))
+  if err != nil {
+    return errorf("%s: %v", filename, err)
+  }
+  n, err := CompareImages(img, golden, tolerance)
+  if err != nil {
+    return errorf("%s: %v", filename, err)
+  }
+  if n > 0 {
+    return errorf("%s: %d pixels differ", filename, n)
+  }
+  return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+// Renders the demo cube and compares the frame with testdata/cube.png.
+// Run with UPDATE_GOLDEN=1 to update the golden image after intended changes.
+func TestSoftwareDeviceCubeGolden(t *testing.T) {
+  w := newTestWorld()
+  dev := NewSoftwareDevice()
+  r := NewRenderer(dev, 160, 120, 1)
+  r.AddToWorld(w)
+  createRenderDemo(w, r)
+  w.Clock.MaxFrameTime = 1 // animate to t=1 in one frame
+  w.Update(0)
+  w.Update(1) // the cube is rotated to show three faces
+
+  if err := CheckGoldenPNG(dev.Image(), "testdata/cube.png", 2); err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestCheckGoldenPNGMissing(t *testing.T) {
+  if os.Getenv("UPDATE_GOLDEN") != "" {
+    t.Skip("UPDATE_GOLDEN is set")
+  }
+  dir, err := ioutil.TempDir("", "golden")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  filename := filepath.Join(dir, "missing.png")
+  dev := NewSoftwareDevice()
+  dev.setCanvasSize(4, 4, 1)
+  if CheckGoldenPNG(dev.Image(), filename, 0) == nil {
+    t.Error("no error for missing golden image")
+  }
+  if _, err := os.Stat(filename); !os.IsNotExist(err) {
+    t.Error("missing golden image was created")
+  }
+}
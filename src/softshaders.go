@@ -0,0 +1,47 @@
+package main
+
+// Go versions of the GLSL programs in render.go, for SoftwareDevice.
+// Keep these in sync with the GLSL source.
+
+func init() {
+  // vertexShaderSrc + fragmentShaderSrc (GLPlane)
+  RegisterSoftShader(vertexShaderSrc, fragmentShaderSrc, &SoftShader{
+    Attribs:  []string{ "aVertexPosition" },
+    Uniforms: []string{
+      "uModelViewMatrix", "uProjectionMatrix", "uResolution", "uPointer", "uTime",
+    },
+    Vertex: func(u SoftUniforms, attribs []Vec4, varyings []float32) Vec4 {
+      projection, modelView := u.Matrix4("uProjectionMatrix"), u.Matrix4("uModelViewMatrix")
+      m := projection.Mul4(&modelView)
+      return m.MulVec4(attribs[0])
+    },
+    Fragment: func(u SoftUniforms, fragCoord Vec4, varyings []float32) Vec4 {
+      resolution, pointer3 := u.Vec2("uResolution"), u.Vec3("uPointer")
+      st := Vec2{ fragCoord[0] / resolution[0], fragCoord[1] / resolution[1] }
+      pointer := Vec2{ 1 - pointer3[0] / resolution[0], 1 - pointer3[1] / resolution[1] }
+      return Vec4{
+        st[0] * pointer[0],
+        st[1] * pointer[1] + pointer3[2],
+        abs32(sin32(u.Float("uTime"))),
+        1,
+      }
+    },
+  })
+
+  // cubeVertexShaderSrc + cubeFragmentShaderSrc (GLCube)
+  RegisterSoftShader(cubeVertexShaderSrc, cubeFragmentShaderSrc, &SoftShader{
+    Attribs:  []string{ "aVertexPosition" },
+    Uniforms: []string{ "uModelViewMatrix", "uProjectionMatrix", "uResolution" },
+    Varyings: 4, // vColor
+    Vertex: func(u SoftUniforms, attribs []Vec4, varyings []float32) Vec4 {
+      projection, modelView := u.Matrix4("uProjectionMatrix"), u.Matrix4("uModelViewMatrix")
+      m := projection.Mul4(&modelView)
+      pos := attribs[0]
+      varyings[0], varyings[1], varyings[2], varyings[3] = pos[0], pos[1], pos[2], 1
+      return m.MulVec4(pos)
+    },
+    Fragment: func(u SoftUniforms, fragCoord Vec4, varyings []float32) Vec4 {
+      return Vec4{ varyings[0], varyings[1], varyings[2], varyings[3] }
+    },
+  })
+}
@@ -1,208 +1,132 @@
 package main
 
-import "fmt"
-
-
-func init() {
-  w := &World{}
-  w.Init()
-
-  player  := w.Ents.Alloc() ; logf("player  %v", player)
-  gun     := w.Ents.Alloc() ; logf("gun     %v", gun)
-  monster := w.Ents.Alloc() ; logf("monster %v", monster)
-  bullet  := w.Ents.Alloc() ; logf("bullet  %v", bullet)
+import (
+  "encoding/binary"
+  "encoding/json"
+  "fmt"
+  "math"
+)
+
+
+// createDemoScene populates w with a few entities and systems.
+// Returns the movement system, for Renderer.Movement.
+func createDemoScene(w *World) *MovementSystem {
+  movement := &MovementSystem{}
+  movement.Init(w)
+  w.AddSystem(movement)
+  w.RegisterComponent("movement", &movement.ComponentStore)
+  w.Schedule(PhaseSimulation, "movement", movement)
+
+  // a player holding a gun
+  playerPrefab := &Prefab{}
+  p := playerPrefab.Add(-1, "player", NewSceneTransform(Matrix4Identity))
+  playerPrefab.Set(p, "movement", MovementData{ mass: 1.0 })
+  playerPrefab.Add(p, "gun", NewSceneTransform(Matrix4Identity))
+
+  if _, err := w.Instantiate(playerPrefab, NilEnt); err != nil {
+    panic(err)
+  }
+  monster := w.Ents.Alloc()
+  bullet  := w.Ents.Alloc()
+  w.Names.SetName(monster, "monster")
+  w.Names.SetName(bullet, "bullet")
+  w.Tags.AddTags(monster, "enemy")
+  w.Tags.AddTags(bullet, "projectile")
+  for _, path := range []string{ "player", "player/gun", "monster", "bullet" } {
+    logf("%-10s %v", path, w.Names.FindPath(path))
+  }
 
-  movement := MovementSystem{}
-  movement.Init()
-  movement.Assoc(player,  MovementData{ mass: 1.0, position: Vec2{0.0, 0.0} })
   movement.Assoc(monster, MovementData{ mass: 1.2, position: Vec2{10.0, 10.0} })
   movement.Assoc(bullet,  MovementData{
     mass: 0.1,
     position: Vec2{0.0, 0.0},
     velocity: Vec2{0.001, 0.001},
   })
-
-  playerNode := w.TransformSystem.CreateNode(player, Matrix4Identity)
-  gunNode := w.TransformSystem.CreateNode(gun, Matrix4Identity)
-  playerNode.AppendChild(gunNode)
-  w.TransformSystem.Update(0.0)
-
-  gunNode.SetLocal(&gunNode.local)
-
-  w.TransformSystem.Update(0.0)
-
-  // movement.Update(1.0 /*seconds*/ )
-  // logf("bullet movement %v", movement.Get(bullet))
-
-  // movement.Update(1.1)
-  // logf("bullet movement %v", movement.Get(bullet))
-
-  // host.events.Listen(EVAnimationFrame, func (_ Event, _ ...uint32) {
-  //   movement.Update(Monotime())
-    // logf("bullet movement %v", movement.Get(bullet))
-  // })
+  return movement
 }
 
+// -------------------------------------------------------------------------------
 
-type TransformSystem struct {
+// MovementSystem integrates velocity and acceleration once per fixed tick of
+// World.Clock. Entities that also have a transform node are moved: the node's X and Y
+// position (relative to its parent) is set to the movement position after each tick.
+type MovementSystem struct {
+  ComponentStore
   world *World
-  nodes []TransformNode
-  dirty []*TransformNode
-  m     map[Ent]*TransformNode
+  data  MovementDataArray
 }
 
-func (s *TransformSystem) Init(world *World) {
+func (s *MovementSystem) Init(world *World) {
   s.world = world
-  s.m = make(map[Ent]*TransformNode)
-}
-
-func (s *TransformSystem) CreateNode(ent Ent, local Matrix4) *TransformNode {
-  s.nodes = append(s.nodes, TransformNode{
-    system: s,
-    ent: ent,
-    local: local,
-    dirty: true,
-  })
-  n := &s.nodes[len(s.nodes)-1]
-  s.m[ent] = n
-  s.markDirty(n)
-  return n
-}
-
-func (s *TransformSystem) Get(ent Ent) *TransformNode {
-  return s.m[ent]
-}
-
-func (s *TransformSystem) Update(time float64) {
-  dirty := s.dirty // just in case some code we run here would call markDirty
-  for _, n := range dirty {
-    if n.dirty {
-      n.computeAbsoluteTransform()
-    }
-  }
-  assert(len(dirty) == len(s.dirty)) // or something called markDirty
-  s.dirty = s.dirty[:0] // truncate
-}
-
-func (s *TransformSystem) markDirty(n *TransformNode) {
-  s.dirty = append(s.dirty, n)
-}
-
-// -----------------------------------------------------------------------------
-
-type TransformNode struct {
-  system      *TransformSystem // Owning system pointer
-  ent         Ent              // The entity owning this instance
-  absolute    Matrix4          // Absolute "world" transform
-  local       Matrix4          // Local transform relative to parent
-  parent      *TransformNode   // The parent instance of this instance
-  firstChild  *TransformNode   // The first child of this instance
-  nextSibling *TransformNode   // The next sibling of this instance
-  prevSibling *TransformNode   // The previous sibling of this instance
-  dirty       bool             // true when local and absolute are not in sync
-}
-
-func (n *TransformNode) AppendChild(child *TransformNode) {
-  child.nextSibling = nil
-  child.parent = n
-  if n.firstChild == nil {
-    n.firstChild = child
-    child.prevSibling = nil
-  } else {
-    // Note: We could add & maintain a lastChild pointer to TransformNode in order to
-    // trade memory for speed if AppendChild turns out to be a frequent operation.
-    // However, note that looping through children is really efficient as they are all
-    // packed together in memory.
-    lastChild := n.firstChild
-    for {
-      if lastChild.nextSibling == nil {
-        lastChild.nextSibling = n
-        n.prevSibling = lastChild
-        break
-      }
-    }
-  }
-  n.markDirty()
-}
-
-func (n *TransformNode) markDirty() {
-  if !n.dirty {
-    n.dirty = true
-    n.system.markDirty(n)
-  }
-}
-
-func (n *TransformNode) SetLocal(m *Matrix4) {
-  n.local = *m
-  n.dirty = true
-  n.system.markDirty(n)
+  s.ComponentStore.Init(&world.Ents, &s.data)
 }
 
-func (n *TransformNode) Translate(x, y, z float32) {
-  n.local.Translate(x, y, z)
-  n.markDirty()
+func (s *MovementSystem) Assoc(ent Ent, data MovementData) {
+  data.prevPosition = data.position
+  s.data[s.Add(ent)] = data
 }
 
-func (n *TransformNode) computeAbsoluteTransform() {
-  logf("computeAbsoluteTransform of %v", n.ent)
-  // compute absolute "world" transform
-  if n.parent != nil {
-    n.absolute = n.local.Mul4(&n.parent.absolute)
-  } else {
-    n.absolute = n.local
+// Get returns the movement data of ent, or nil if ent has no movement data
+func (s *MovementSystem) Get(ent Ent) *MovementData {
+  if DEBUG {
+    checkAlive(s.em, ent, 0)
   }
-
-  // traverse all children to update their transforms
-  child := n.firstChild
-  for child != nil {
-    child.computeAbsoluteTransform()
-    child = child.nextSibling
+  if i := s.Index(ent); i != -1 {
+    return &s.data[i]
   }
-
-  n.dirty = false
+  return nil
 }
 
-
-// -------------------------------------------------------------------------------
-
-type MovementSystem struct {
-  data           []MovementData
-  m              map[Ent]int
-  lastUpdateTime float64
+// At returns the movement data at dense index i, e.g. from Query.Index
+func (s *MovementSystem) At(i int) *MovementData {
+  return &s.data[i]
 }
 
-func (s *MovementSystem) Init() {
-  s.m = make(map[Ent]int)
-}
-
-func (s *MovementSystem) Assoc(ent Ent, data MovementData) {
-  index := len(s.data)
-  s.m[ent] = index
-  s.data = append(s.data, data)
-}
-
-func (s *MovementSystem) Get(ent Ent) *MovementData {
-  index := s.m[ent]
-  return &s.data[index]
+func (s *MovementSystem) DestroyEnt(ent Ent) {
+  s.Remove(ent)
 }
 
+// Update runs one fixed simulation tick of World.Clock.FixedStep seconds
 func (s *MovementSystem) Update(time float64) {
-  dt := float32(time - s.lastUpdateTime)
-  s.lastUpdateTime = time
-
+  dt := float32(s.world.Clock.FixedStep)
   for i := 0; i < len(s.data); i++ {
     data := &s.data[i]
+    data.prevPosition = data.position
     data.velocity = data.velocity.Add(data.acceleration.Mul(dt))
     data.position = data.position.Add(data.velocity.Mul(dt))
+    if data.position != data.prevPosition {
+      s.markChangedAt(i)
+      // entities freed without World.Destroy still have data here but are not moved
+      if e := s.EntAt(i); s.world.Ents.IsAlive(e) {
+        if n := s.world.TransformSystem.Get(e); n != nil {
+          n.SetPosition(Vec3{ data.position[0], data.position[1], n.position[2] })
+        }
+      }
+    }
   }
 }
 
 
+type MovementDataArray []MovementData
+
+func (a *MovementDataArray) Append()               { *a = append(*a, MovementData{}) }
+func (a *MovementDataArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *MovementDataArray) Truncate(n int)        { *a = (*a)[:n] }
+func (a *MovementDataArray) Ptr(i int) interface{} { return &(*a)[i] }
+
 type MovementData struct {
   mass         float32
   position     Vec2
   velocity     Vec2
   acceleration Vec2
+  prevPosition Vec2 // position before the most recent tick
+}
+
+// InterpolatedPosition returns the position blended between the previous and the
+// current tick by alpha, usually World.Clock.Alpha. Used for rendering (see
+// Renderer.Movement.)
+func (d *MovementData) InterpolatedPosition(alpha float32) Vec2 {
+  return d.prevPosition.Add(d.position.Sub(d.prevPosition).Mul(alpha))
 }
 
 func (d MovementData) String() string {
@@ -215,15 +139,50 @@ func (d MovementData) String() string {
 }
 
 
+type movementDataJSON struct {
+  Mass         float32 `json:"mass"`
+  Position     Vec2    `json:"position"`
+  Velocity     Vec2    `json:"velocity"`
+  Acceleration Vec2    `json:"acceleration"`
+}
 
-type World struct {
-  Time float64
-  Ents EntManager
+func (d MovementData) MarshalJSON() ([]byte, error) {
+  return json.Marshal(movementDataJSON{ d.mass, d.position, d.velocity, d.acceleration })
+}
 
-  TransformSystem
+func (d *MovementData) UnmarshalJSON(data []byte) error {
+  var v movementDataJSON
+  if err := json.Unmarshal(data, &v); err != nil {
+    return err
+  }
+  *d = MovementData{ v.Mass, v.Position, v.Velocity, v.Acceleration, v.Position }
+  return nil
 }
 
-func (w *World) Init() {
-  w.Ents.Init()
-  w.TransformSystem.Init(w)
+func (d MovementData) MarshalBinary() ([]byte, error) {
+  b := make([]byte, 7*4)
+  for i, v := range [7]float32{
+    d.mass,
+    d.position[0], d.position[1],
+    d.velocity[0], d.velocity[1],
+    d.acceleration[0], d.acceleration[1],
+  } {
+    binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v))
+  }
+  return b, nil
+}
+
+func (d *MovementData) UnmarshalBinary(b []byte) error {
+  if len(b) != 7*4 {
+    return errorf("invalid MovementData size %d", len(b))
+  }
+  f := func(i int) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:])) }
+  *d = MovementData{
+    mass:         f(0),
+    position:     Vec2{f(1), f(2)},
+    velocity:     Vec2{f(3), f(4)},
+    acceleration: Vec2{f(5), f(6)},
+  }
+  d.prevPosition = d.position
+  return nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestMovementFixedStep(t *testing.T) {
+  w, movement := newSceneTestWorld()
+  w.Schedule(PhaseSimulation, "movement", movement)
+  r := NewRenderer(NewRecordingDevice(), 100, 100, 1)
+  r.AddToWorld(w)
+  r.Movement = movement
+
+  e := w.Ents.Alloc()
+  w.CreateNode(e, Matrix4Identity)
+  movement.Assoc(e, MovementData{ mass: 1, velocity: Vec2{ 1, 0 } })
+
+  // the first update only starts the clock, no matter how late it is
+  step := w.Clock.FixedStep
+  w.Update(100)
+  if x := movement.Get(e).position[0]; x != 0 {
+    t.Fatalf("moved to %v on first update", x)
+  }
+
+  // two and a half ticks; two are run and the node follows
+  w.Update(100 + 2.5 * step)
+  want := float32(2 * step)
+  if x := movement.Get(e).position[0]; x != want {
+    t.Errorf("position %v after two ticks, expected %v", x, want)
+  }
+  if x := w.TransformSystem.Get(e).WorldPosition()[0]; x != want {
+    t.Errorf("node at %v after two ticks, expected %v", x, want)
+  }
+
+  // the renderer draws the node half way between the previous and the current tick
+  m := r.nodeTransform(w.TransformSystem.Get(e))
+  if x, want := m[12], float32(1.5 * step); abs32(x - want) > 1e-6 {
+    t.Errorf("drawn at %v with alpha %v, expected %v", x, w.Clock.Alpha, want)
+  }
+}
+
+// Children of a moving node are drawn relative to the interpolated position of their
+// parent, so that they move as smoothly as the parent
+func TestMovementInterpolatesChildren(t *testing.T) {
+  w, movement := newSceneTestWorld()
+  w.Schedule(PhaseSimulation, "movement", movement)
+  r := NewRenderer(NewRecordingDevice(), 100, 100, 1)
+  r.AddToWorld(w)
+  r.Movement = movement
+
+  parent, child := w.Ents.Alloc(), w.Ents.Alloc()
+  w.CreateNode(parent, Matrix4Identity)
+  tm := Matrix4Identity
+  tm.Translate(0, 1, 0)
+  w.CreateNode(child, tm)
+  w.AppendChild(parent, child)
+  movement.Assoc(parent, MovementData{ mass: 1, velocity: Vec2{ 1, 0 } })
+
+  step := w.Clock.FixedStep
+  w.Update(0)
+  if rt := w.Clock.RenderTime(); rt != 0 {
+    t.Errorf("render time %v on first frame, expected 0", rt)
+  }
+  w.Update(2.5 * step)
+  m := r.nodeTransform(w.TransformSystem.Get(child))
+  if x, y, want := m[12], m[13], float32(1.5 * step); abs32(x - want) > 1e-6 || y != 1 {
+    t.Errorf("child drawn at %v,%v, expected %v,1", x, y, want)
+  }
+}
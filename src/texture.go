@@ -0,0 +1,46 @@
+package main
+
+import (
+  "image"
+  "image/draw"
+)
+
+// Texture is a 2D RGBA texture on a GraphicsDevice, sampled with linear filtering.
+// Textures are bound to materials with Material.SetTexture.
+type Texture struct {
+  Width, Height int
+  dev           GraphicsDevice
+  tex           GLTexture
+}
+
+// NewTexture creates a texture on dev with the pixels of img. The top row of img is
+// at t=0. wrap is the wrap mode for both directions, GL_CLAMP_TO_EDGE or GL_REPEAT.
+// Note that WebGL only supports GL_REPEAT for textures with power-of-two sizes.
+func NewTexture(dev GraphicsDevice, img image.Image, wrap uint32) *Texture {
+  rgba, ok := img.(*image.RGBA)
+  if !ok || rgba.Stride != rgba.Rect.Dx() * 4 {
+    rgba = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+    draw.Draw(rgba, rgba.Rect, img, img.Bounds().Min, draw.Src)
+  }
+  t := &Texture{
+    Width:  rgba.Rect.Dx(),
+    Height: rgba.Rect.Dy(),
+    dev:    dev,
+    tex:    dev.createTexture(),
+  }
+  dev.bindTexture(GL_TEXTURE_2D, t.tex)
+  dev.texParameteri(GL_TEXTURE_2D, GL_TEXTURE_MIN_FILTER, int32(GL_LINEAR))
+  dev.texParameteri(GL_TEXTURE_2D, GL_TEXTURE_MAG_FILTER, int32(GL_LINEAR))
+  dev.texParameteri(GL_TEXTURE_2D, GL_TEXTURE_WRAP_S, int32(wrap))
+  dev.texParameteri(GL_TEXTURE_2D, GL_TEXTURE_WRAP_T, int32(wrap))
+  if t.Width > 0 && t.Height > 0 {
+    dev.texImage2D(GL_TEXTURE_2D, uint32(t.Width), uint32(t.Height), rgba.Pix)
+  }
+  return t
+}
+
+// Free deletes the texture from its device. t must not be used after this call.
+func (t *Texture) Free() {
+  t.dev.deleteTexture(t.tex)
+  t.tex = GLTexture{}
+}
@@ -0,0 +1,475 @@
+package main
+
+import "sort"
+
+// TransformSystem maintains a hierarchy of transforms.
+//
+// Nodes refer to each other by entity rather than by pointer since nodes are stored in
+// a ComponentStore and move around in memory as nodes are created and removed.
+// Pointers returned by CreateNode and Get are only valid until the next call to
+// CreateNode or a removal of a node. Changing the hierarchy (AppendChild, Reparent
+// etc) does not move nodes in memory.
+//
+type TransformSystem struct {
+  world *World
+  store ComponentStore
+  nodes TransformNodeArray
+  dirty []Ent // nodes with dirty=true, in no particular order
+
+  DestroyPolicy TransformDestroyPolicy // what happens to children of destroyed nodes
+}
+
+// TransformDestroyPolicy decides what happens to the children of a node when the node's
+// entity is destroyed.
+type TransformDestroyPolicy int
+const (
+  DestroyChildren = TransformDestroyPolicy(iota) // destroy children along with parent
+  DetachChildren                                 // children become root nodes
+)
+
+func (s *TransformSystem) Init(world *World) {
+  s.world = world
+  s.store.Init(&world.Ents, &s.nodes)
+  s.dirty = s.dirty[:0]
+}
+
+// CreateNode creates a root node for ent. If ent already has a node, it's detached
+// from its parent and given the local transform instead; its children are kept.
+func (s *TransformSystem) CreateNode(ent Ent, local Matrix4) *TransformNode {
+  if n := s.Get(ent); n != nil {
+    s.unlink(n)
+    s.setDepth(n, 0)
+    n.setLocal(&local)
+    s.markDirty(n)
+    return n
+  }
+  n := &s.nodes[s.store.Add(ent)]
+  *n = TransformNode{
+    system: s,
+    ent: ent,
+  }
+  n.setLocal(&local)
+  s.markDirty(n)
+  return n
+}
+
+// Get returns the node of ent, or nil if ent does not have a transform
+func (s *TransformSystem) Get(ent Ent) *TransformNode {
+  if DEBUG {
+    checkAlive(&s.world.Ents, ent, 0)
+  }
+  if i := s.store.Index(ent); i != -1 {
+    return &s.nodes[i]
+  }
+  return nil
+}
+
+// node returns the node of ent, or nil if ent has no node. Unlike Get, it also finds
+// the nodes of entities that were freed without being destroyed, which are still linked
+// into the hierarchy until removed by DestroyEnt. Used for walking the hierarchy.
+func (s *TransformSystem) node(ent Ent) *TransformNode {
+  if i := s.store.storedIndex(ent); i != -1 {
+    return &s.nodes[i]
+  }
+  return nil
+}
+
+// NodeAt returns the node at dense index i, e.g. from Query.Index
+func (s *TransformSystem) NodeAt(i int) *TransformNode {
+  return &s.nodes[i]
+}
+
+// mustGet returns the node of ent, or panics if there's none.
+// skip is the number of stack frames between the caller of mustGet and the exported
+// method called by the user, e.g. 1 for insertBefore, so that a dead entity is
+// reported at the user's call to e.g. AppendChild.
+func (s *TransformSystem) mustGet(ent Ent, skip int) *TransformNode {
+  if DEBUG {
+    checkAlive(&s.world.Ents, ent, skip + 1)
+  }
+  n := s.Get(ent)
+  if n == nil {
+    panicf("TransformSystem: %#v has no transform node", ent)
+  }
+  return n
+}
+
+// AppendChild makes child the last child of parent.
+// If child already has a parent, it's first removed from that parent.
+// child's local transform is kept as-is.
+func (s *TransformSystem) AppendChild(parent, child Ent) {
+  s.insertBefore(parent, child, NilEnt)
+}
+
+// InsertBefore makes child a child of parent, placed before the sibling before.
+// If before is NilEnt, child is placed last.
+// If child already has a parent, it's first removed from that parent.
+// child's local transform is kept as-is.
+func (s *TransformSystem) InsertBefore(parent, child, before Ent) {
+  s.insertBefore(parent, child, before)
+}
+
+func (s *TransformSystem) insertBefore(parent, child, before Ent) {
+  p := s.mustGet(parent, 1)
+  c := s.mustGet(child, 1)
+  for a := p; a != nil; a = s.node(a.parent) {
+    if a.ent == child {
+      panicf("TransformSystem.InsertBefore: %#v is an ancestor of %#v", child, parent)
+    }
+  }
+  s.unlink(c)
+  c.parent = parent
+  if before == NilEnt {
+    if p.firstChild == NilEnt {
+      p.firstChild = child
+    } else {
+      // Note: We could add & maintain a lastChild field to TransformNode in order to
+      // trade memory for speed if AppendChild turns out to be a frequent operation.
+      last := s.node(p.firstChild)
+      for last.nextSibling != NilEnt {
+        last = s.node(last.nextSibling)
+      }
+      last.nextSibling = child
+      c.prevSibling = last.ent
+    }
+  } else {
+    b := s.mustGet(before, 1)
+    if b.parent != parent {
+      panicf("TransformSystem.InsertBefore: %#v is not a child of %#v", before, parent)
+    }
+    c.nextSibling = before
+    c.prevSibling = b.prevSibling
+    if b.prevSibling != NilEnt {
+      s.node(b.prevSibling).nextSibling = child
+    } else {
+      p.firstChild = child
+    }
+    b.prevSibling = child
+  }
+  s.setDepth(c, p.depth + 1)
+  s.markDirty(c)
+}
+
+// RemoveChild removes child from parent, making child a root node.
+// child's local transform is kept as-is, which means that its absolute transform
+// changes. Returns false if child is not a child of parent.
+func (s *TransformSystem) RemoveChild(parent, child Ent) bool {
+  c := s.Get(child)
+  if c == nil || c.parent != parent || parent == NilEnt {
+    return false
+  }
+  s.unlink(c)
+  s.setDepth(c, 0)
+  s.markDirty(c)
+  return true
+}
+
+// Reparent moves child to become the last child of parent while preserving child's
+// absolute transform. If parent is NilEnt, child becomes a root node.
+func (s *TransformSystem) Reparent(child, parent Ent) {
+  s.reparent(child, parent)
+}
+
+func (s *TransformSystem) reparent(child, parent Ent) {
+  c := s.mustGet(child, 1)
+  absolute := s.computeAbsolute(c)
+  if parent == NilEnt {
+    s.unlink(c)
+    s.setDepth(c, 0)
+    c.setLocal(&absolute)
+    s.markDirty(c)
+    return
+  }
+  parentAbsolute := s.computeAbsolute(s.mustGet(parent, 1))
+  inv := parentAbsolute.Inverse()
+  s.AppendChild(parent, child)
+  local := inv.Mul4(&absolute)
+  c.setLocal(&local)
+}
+
+// Detach makes child a root node while preserving its absolute transform
+func (s *TransformSystem) Detach(child Ent) {
+  s.reparent(child, NilEnt)
+}
+
+// DestroySubtree destroys ent and all of its descendants, regardless of DestroyPolicy.
+func (s *TransformSystem) DestroySubtree(ent Ent) {
+  policy := s.DestroyPolicy
+  s.DestroyPolicy = DestroyChildren
+  defer func() { s.DestroyPolicy = policy }()
+  if !s.world.Destroy(ent) {
+    s.DestroyEnt(ent)
+  }
+}
+
+// DestroyEnt removes the node of ent, handling its children according to DestroyPolicy.
+func (s *TransformSystem) DestroyEnt(ent Ent) {
+  n := s.node(ent)
+  if n == nil {
+    return
+  }
+  for n.firstChild != NilEnt {
+    child := n.firstChild
+    if !s.world.Ents.IsAlive(child) {
+      // child's entity was freed without being destroyed; remove its node as if it had
+      // been destroyed, which also takes care of its children
+      s.DestroyEnt(child)
+    } else if s.DestroyPolicy == DetachChildren {
+      s.Detach(child)
+    } else {
+      s.world.Destroy(child)
+    }
+    // Note: removing a child's node may move n in memory, so look it up again
+    n = s.node(ent)
+  }
+  s.unlink(n)
+  s.store.Remove(ent)
+}
+
+// Update computes absolute transforms of nodes that have changed since the last update.
+//
+// Dirty nodes are visited in order of depth, parents before children. When a node is
+// computed, so are all of its descendants, which also clears their dirty flag. This
+// way every node is computed at most once per update.
+func (s *TransformSystem) Update(time float64) {
+  dirty := s.dirty
+  sort.Slice(dirty, func(i, j int) bool {
+    return s.depthOf(dirty[i]) < s.depthOf(dirty[j])
+  })
+  for _, ent := range dirty {
+    if n := s.node(ent); n != nil && n.dirty {
+      s.computeSubtree(n)
+    }
+  }
+  assert(len(dirty) == len(s.dirty)) // or something called markDirty
+  s.dirty = s.dirty[:0] // truncate
+}
+
+func (s *TransformSystem) depthOf(ent Ent) int {
+  if n := s.node(ent); n != nil {
+    return int(n.depth)
+  }
+  return 0
+}
+
+func (s *TransformSystem) markDirty(n *TransformNode) {
+  if !n.dirty {
+    n.dirty = true
+    s.dirty = append(s.dirty, n.ent)
+  }
+}
+
+// unlink removes n from its parent's list of children
+func (s *TransformSystem) unlink(n *TransformNode) {
+  if n.prevSibling != NilEnt {
+    s.node(n.prevSibling).nextSibling = n.nextSibling
+  } else if n.parent != NilEnt {
+    s.node(n.parent).firstChild = n.nextSibling
+  }
+  if n.nextSibling != NilEnt {
+    s.node(n.nextSibling).prevSibling = n.prevSibling
+  }
+  n.parent = NilEnt
+  n.prevSibling = NilEnt
+  n.nextSibling = NilEnt
+}
+
+// setDepth sets the depth of n and updates the depth of n's descendants
+func (s *TransformSystem) setDepth(n *TransformNode, depth int32) {
+  if n.depth == depth {
+    return
+  }
+  n.depth = depth
+  for child := n.firstChild; child != NilEnt; {
+    c := s.node(child)
+    s.setDepth(c, depth + 1)
+    child = c.nextSibling
+  }
+}
+
+// computeSubtree computes the absolute transform of n and all of its descendants
+func (s *TransformSystem) computeSubtree(n *TransformNode) {
+  n.updateLocal()
+  if n.parent != NilEnt {
+    parent := s.node(n.parent)
+    n.absolute = parent.absolute.Mul4(&n.local)
+  } else {
+    n.absolute = n.local
+  }
+  n.dirty = false
+  // let observers and Query.Changed know that the absolute transform changed
+  s.store.MarkChanged(n.ent)
+  for child := n.firstChild; child != NilEnt; {
+    c := s.node(child)
+    s.computeSubtree(c)
+    child = c.nextSibling
+  }
+}
+
+// computeAbsolute returns the absolute transform of n, computing it from the local
+// transforms of n and its ancestors if needed, without updating any nodes.
+func (s *TransformSystem) computeAbsolute(n *TransformNode) Matrix4 {
+  if !s.isDirty(n) {
+    return n.absolute
+  }
+  n.updateLocal()
+  if n.parent == NilEnt {
+    return n.local
+  }
+  parent := s.computeAbsolute(s.node(n.parent))
+  return parent.Mul4(&n.local)
+}
+
+// isDirty returns true if n or any of its ancestors is dirty
+func (s *TransformSystem) isDirty(n *TransformNode) bool {
+  for ; n != nil; n = s.node(n.parent) {
+    if n.dirty {
+      return true
+    }
+  }
+  return false
+}
+
+// -----------------------------------------------------------------------------
+
+type TransformNodeArray []TransformNode
+
+func (a *TransformNodeArray) Append()               { *a = append(*a, TransformNode{}) }
+func (a *TransformNodeArray) Move(dst, src int)     { (*a)[dst] = (*a)[src] }
+func (a *TransformNodeArray) Truncate(n int)        { *a = (*a)[:n] }
+func (a *TransformNodeArray) Ptr(i int) interface{} { return &(*a)[i] }
+
+type TransformNode struct {
+  system      *TransformSystem // Owning system pointer
+  ent         Ent              // The entity owning this instance
+  absolute    Matrix4          // Absolute "world" transform
+  local       Matrix4          // Local transform relative to parent
+  position    Vec3             // Translation component of local
+  rotation    Quat             // Rotation component of local
+  scale       Vec3             // Scale component of local
+  parent      Ent              // The parent of this node (NilEnt for root nodes)
+  firstChild  Ent              // The first child of this node
+  nextSibling Ent              // The next sibling of this node
+  prevSibling Ent              // The previous sibling of this node
+  depth       int32            // Number of ancestors
+  dirty       bool             // true when local and absolute are not in sync
+  localDirty  bool             // true when local needs to be composed from TRS
+}
+
+func (n *TransformNode) Ent() Ent         { return n.ent }
+func (n *TransformNode) Parent() Ent      { return n.parent }
+func (n *TransformNode) FirstChild() Ent  { return n.firstChild }
+func (n *TransformNode) NextSibling() Ent { return n.nextSibling }
+func (n *TransformNode) PrevSibling() Ent { return n.prevSibling }
+func (n *TransformNode) Depth() int       { return int(n.depth) }
+
+// Local returns the transform relative to the parent
+func (n *TransformNode) Local() Matrix4 {
+  n.updateLocal()
+  return n.local
+}
+
+// Absolute returns the "world" transform as of the last update
+func (n *TransformNode) Absolute() Matrix4 { return n.absolute }
+
+// AppendChild makes child the last child of n. See TransformSystem.AppendChild
+func (n *TransformNode) AppendChild(child *TransformNode) {
+  n.system.AppendChild(n.ent, child.ent)
+}
+
+// SetLocal replaces the local transform. Position, rotation and scale are decomposed
+// from m; the matrix is used as-is until any of them are changed, after which local
+// is composed from position, rotation and scale (losing any shear in m.)
+func (n *TransformNode) SetLocal(m *Matrix4) {
+  n.setLocal(m)
+  n.system.markDirty(n)
+}
+
+// Translate moves the node by x,y,z in its own (rotated and scaled) coordinate space
+func (n *TransformNode) Translate(x, y, z float32) {
+  v := n.rotation.Rotate(n.scale.MulVec(Vec3{x, y, z}))
+  n.SetPosition(n.position.Add(v))
+}
+
+// Rotate rotates the node by q in its own coordinate space
+func (n *TransformNode) Rotate(q Quat) {
+  n.SetRotation(n.rotation.Mul(q))
+}
+
+func (n *TransformNode) Position() Vec3 { return n.position }
+func (n *TransformNode) Rotation() Quat { return n.rotation }
+func (n *TransformNode) Scale() Vec3    { return n.scale }
+
+func (n *TransformNode) SetPosition(v Vec3) {
+  n.position = v
+  n.localChanged()
+}
+
+func (n *TransformNode) SetRotation(q Quat) {
+  n.rotation = q.Normalize()
+  n.localChanged()
+}
+
+func (n *TransformNode) SetScale(v Vec3) {
+  n.scale = v
+  n.localChanged()
+}
+
+// LookAt rotates the node so that its negative Z axis points at target, with its Y
+// axis towards up, like a camera (see Camera.) target and up are in world space.
+// The transforms of ancestors are used as of the last update.
+func (n *TransformNode) LookAt(target, up Vec3) {
+  eye := n.position
+  var parentRotation Quat
+  if n.parent != NilEnt {
+    parent := n.system.mustGet(n.parent, 0)
+    p := parent.absolute.MulVec4(Vec4{ eye[0], eye[1], eye[2], 1 })
+    eye = Vec3{ p[0], p[1], p[2] }
+    parentRotation = parent.WorldRotation()
+  }
+  // the rotation of a view matrix is the inverse of the eye's world rotation
+  view := Matrix4LookAt(eye, target, up)
+  _, rotation, _ := view.Decompose()
+  rotation = rotation.Inverse()
+  if n.parent != NilEnt {
+    rotation = parentRotation.Inverse().Mul(rotation)
+  }
+  n.SetRotation(rotation)
+}
+
+// WorldPosition returns the position in world space as of the last update
+func (n *TransformNode) WorldPosition() Vec3 {
+  return Vec3{n.absolute[12], n.absolute[13], n.absolute[14]}
+}
+
+// WorldRotation returns the rotation in world space as of the last update
+func (n *TransformNode) WorldRotation() Quat {
+  _, r, _ := n.absolute.Decompose()
+  return r
+}
+
+// WorldScale returns the scale in world space as of the last update.
+// This is an approximation when a non-uniformly scaled ancestor has rotated children.
+func (n *TransformNode) WorldScale() Vec3 {
+  _, _, scale := n.absolute.Decompose()
+  return scale
+}
+
+func (n *TransformNode) setLocal(m *Matrix4) {
+  n.local = *m
+  n.position, n.rotation, n.scale = m.Decompose()
+  n.localDirty = false
+}
+
+func (n *TransformNode) localChanged() {
+  n.localDirty = true
+  n.system.markDirty(n)
+}
+
+// updateLocal composes local from position, rotation and scale if needed
+func (n *TransformNode) updateLocal() {
+  if n.localDirty {
+    n.local = Matrix4Compose(n.position, n.rotation, n.scale)
+    n.localDirty = false
+  }
+}
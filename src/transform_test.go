@@ -0,0 +1,155 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+)
+
+func newTestWorld() *World {
+  w := &World{}
+  w.Init()
+  return w
+}
+
+// A child whose entity was freed without World.Destroy is still linked to its parent.
+// Destroying the parent must remove the child's node rather than crash or loop.
+func TestTransformDestroyParentOfFreedChild(t *testing.T) {
+  for _, policy := range []TransformDestroyPolicy{ DestroyChildren, DetachChildren } {
+    w := newTestWorld()
+    w.DestroyPolicy = policy
+    parent, child, grandchild, sibling := w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc()
+    for _, e := range []Ent{ parent, child, grandchild, sibling } {
+      w.CreateNode(e, Matrix4Identity)
+    }
+    w.AppendChild(parent, child)
+    w.AppendChild(parent, sibling)
+    w.AppendChild(child, grandchild)
+
+    w.Ents.Free(child)
+    if !w.Destroy(parent) {
+      t.Fatalf("policy %d: Destroy(parent) returned false", policy)
+    }
+
+    if w.TransformSystem.node(child) != nil {
+      t.Errorf("policy %d: node of freed child was not removed", policy)
+    }
+    if w.TransformSystem.node(parent) != nil {
+      t.Errorf("policy %d: node of parent was not removed", policy)
+    }
+    wantNodes := 0
+    switch policy {
+    case DestroyChildren:
+      if w.Ents.IsAlive(grandchild) || w.Ents.IsAlive(sibling) {
+        t.Errorf("descendants of destroyed parent are still alive")
+      }
+    case DetachChildren:
+      wantNodes = 2
+      for _, e := range []Ent{ grandchild, sibling } {
+        n := w.TransformSystem.Get(e)
+        if n == nil || n.Parent() != NilEnt || n.Depth() != 0 {
+          t.Errorf("%v was not detached", e)
+        }
+      }
+    }
+    if got := w.TransformSystem.store.Len(); got != wantNodes {
+      t.Errorf("policy %d: %d nodes left", policy, got)
+    }
+    w.Update(0) // must not touch removed nodes
+  }
+}
+
+// Creating a node for an entity that already has one must keep the tree consistent
+func TestTransformCreateNodeTwice(t *testing.T) {
+  w := newTestWorld()
+  parent, child, sibling, grandchild := w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc()
+  for _, e := range []Ent{ parent, child, sibling, grandchild } {
+    w.CreateNode(e, Matrix4Identity)
+  }
+  w.AppendChild(parent, child)
+  w.AppendChild(parent, sibling)
+  w.AppendChild(child, grandchild)
+
+  tm := Matrix4Identity
+  tm.Translate(1, 2, 3)
+  w.Commands.CreateNode(child, tm)
+  w.Commands.CreateNode(child, tm)
+  w.Update(0)
+
+  c := w.Get(child)
+  if c.Parent() != NilEnt || c.Depth() != 0 {
+    t.Errorf("node created again has parent %v and depth %d", c.Parent(), c.Depth())
+  }
+  if p := w.Get(parent); p.FirstChild() != sibling || w.Get(sibling).PrevSibling() != NilEnt {
+    t.Errorf("node created again is still linked to its old parent")
+  }
+  g := w.Get(grandchild)
+  if g.Parent() != child || c.FirstChild() != grandchild || g.Depth() != 1 {
+    t.Errorf("children of node created again were not kept")
+  }
+  if p := g.WorldPosition(); p != (Vec3{ 1, 2, 3 }) {
+    t.Errorf("grandchild at %v; expected it to follow the new local transform", p)
+  }
+}
+
+// Commands and systems must skip entities freed before the commands are applied or
+// the systems run, also in debug builds where looking up a dead entity panics
+func TestTransformFreedEntitiesSkipped(t *testing.T) {
+  w, movement := newSceneTestWorld()
+  w.Schedule(PhaseSimulation, "movement", movement)
+  controls := &CameraControlSystem{}
+  controls.AddToWorld(w)
+  parent, child, moving := w.Ents.Alloc(), w.Ents.Alloc(), w.Ents.Alloc()
+  for _, e := range []Ent{ parent, child, moving } {
+    w.CreateNode(e, Matrix4Identity)
+  }
+  movement.Assoc(moving, MovementData{ mass: 1, velocity: Vec2{ 1, 0 } })
+  controls.Assoc(moving, OrbitCameraControl(Vec3{}, 5))
+  w.Names.SetName(moving, "moving")
+
+  w.Commands.Destroy(child)
+  w.Commands.SetParent(child, parent)
+  w.Commands.Reparent(child, parent)
+  w.Commands.SetParent(parent, child)
+  w.Ents.Free(moving)
+  w.Update(0)
+  w.Update(1)
+
+  if w.Get(parent).FirstChild() != NilEnt {
+    t.Errorf("destroyed entity was added as a child")
+  }
+  if x := w.TransformSystem.node(moving).position[0]; x != 0 {
+    t.Errorf("freed entity was moved to %v", x)
+  }
+  if w.Names.FindPath("moving") != NilEnt {
+    t.Errorf("freed entity found by path")
+  }
+}
+
+// In debug builds, using a dead entity reports the caller of the exported method
+func TestTransformDeadEntityCallSite(t *testing.T) {
+  if !DEBUG {
+    t.Skip("requires -tags debug")
+  }
+  w := newTestWorld()
+  parent, child := w.Ents.Alloc(), w.Ents.Alloc()
+  w.CreateNode(parent, Matrix4Identity)
+  w.CreateNode(child, Matrix4Identity)
+  w.Ents.Free(child)
+  for name, fn := range map[string]func(){
+    "AppendChild":  func() { w.AppendChild(parent, child) },
+    "InsertBefore": func() { w.InsertBefore(parent, child, NilEnt) },
+    "Reparent":     func() { w.Reparent(child, parent) },
+    "Detach":       func() { w.Detach(child) },
+  } {
+    func() {
+      defer func() {
+        msg := fmt.Sprint(recover())
+        if !strings.Contains(msg, "transform_test.go:") {
+          t.Errorf("%s: panic %q does not report the call site", name, msg)
+        }
+      }()
+      fn()
+    }()
+  }
+}
@@ -7,6 +7,8 @@ import (
 
 type Vec2    mgl32.Vec2
 type Vec3    mgl32.Vec3
+type Vec4    mgl32.Vec4
+type Quat    mgl32.Quat
 type Matrix4 mgl32.Mat4
 
 
@@ -17,6 +19,32 @@ func (v Vec2) Mul(b float32) Vec2 { return Vec2(mgl32.Vec2(v).Mul(b)) }
 func (v Vec3) Add(b Vec3) Vec3 { return Vec3(mgl32.Vec3(v).Add(mgl32.Vec3(b))) }
 func (v Vec3) Sub(b Vec3) Vec3 { return Vec3(mgl32.Vec3(v).Sub(mgl32.Vec3(b))) }
 func (v Vec3) Mul(b float32) Vec3 { return Vec3(mgl32.Vec3(v).Mul(b)) }
+func (v Vec3) Len() float32 { return mgl32.Vec3(v).Len() }
+
+// MulVec returns the component-wise product of v and b
+func (v Vec3) MulVec(b Vec3) Vec3 { return Vec3{v[0]*b[0], v[1]*b[1], v[2]*b[2]} }
+
+
+var QuatIdentity = Quat{ W: 1 }
+
+// QuatRotate returns a rotation of radians around axis (which should be normalized)
+func QuatRotate(radians float32, axis Vec3) Quat {
+  return Quat(mgl32.QuatRotate(radians, mgl32.Vec3(axis)))
+}
+
+// QuatEuler returns a rotation of radians around the X, Y and Z axes, applied in that order
+func QuatEuler(radiansX, radiansY, radiansZ float32) Quat {
+  x := QuatRotate(radiansX, Vec3{1, 0, 0})
+  y := QuatRotate(radiansY, Vec3{0, 1, 0})
+  z := QuatRotate(radiansZ, Vec3{0, 0, 1})
+  return z.Mul(y).Mul(x)
+}
+
+func (q Quat) Mul(b Quat) Quat { return Quat(mgl32.Quat(q).Mul(mgl32.Quat(b))) }
+func (q Quat) Normalize() Quat { return Quat(mgl32.Quat(q).Normalize()) }
+func (q Quat) Inverse() Quat { return Quat(mgl32.Quat(q).Inverse()) }
+func (q Quat) Rotate(v Vec3) Vec3 { return Vec3(mgl32.Quat(q).Rotate(mgl32.Vec3(v))) }
+func (q Quat) Matrix4() Matrix4 { return Matrix4(mgl32.Quat(q).Mat4()) }
 
 
 var Matrix4Identity = Matrix4{
@@ -39,6 +67,26 @@ func Matrix4Perspective(fovy, aspect, near, far float32) Matrix4 {
   // }
 }
 
+// Matrix4Orthographic returns an orthographic projection of the box between left,
+// right, bottom, top and the near and far planes (as distances along -Z)
+func Matrix4Orthographic(left, right, bottom, top, near, far float32) Matrix4 {
+  return Matrix4(mgl32.Ortho(left, right, bottom, top, near, far))
+}
+
+// Matrix4LookAt returns a view matrix for an eye at eye looking at center, with up
+// as the general up direction
+func Matrix4LookAt(eye, center, up Vec3) Matrix4 {
+  return Matrix4(mgl32.LookAtV(mgl32.Vec3(eye), mgl32.Vec3(center), mgl32.Vec3(up)))
+}
+
+// Matrix4Compose returns a matrix that scales, then rotates and then translates
+func Matrix4Compose(translation Vec3, rotation Quat, scale Vec3) Matrix4 {
+  m := rotation.Matrix4()
+  m.Scale(scale[0], scale[1], scale[2])
+  m[12], m[13], m[14] = translation[0], translation[1], translation[2]
+  return m
+}
+
 // Matrix4Scale returns an identity matrix with scale
 func Matrix4Scale(scaleX, scaleY, scaleZ float32) Matrix4 {
   return Matrix4{
@@ -114,6 +162,16 @@ func (m1 *Matrix4) Mul4(m2 *Matrix4) Matrix4 {
   }
 }
 
+// MulVec4 returns the product of m and the column vector v
+func (m *Matrix4) MulVec4(v Vec4) Vec4 {
+  return Vec4{
+    m[0]*v[0] + m[4]*v[1] + m[8] *v[2] + m[12]*v[3],
+    m[1]*v[0] + m[5]*v[1] + m[9] *v[2] + m[13]*v[3],
+    m[2]*v[0] + m[6]*v[1] + m[10]*v[2] + m[14]*v[3],
+    m[3]*v[0] + m[7]*v[1] + m[11]*v[2] + m[15]*v[3],
+  }
+}
+
 func (m *Matrix4) Mul4Mut(m2 *Matrix4) {
   v0, v1,  v2,  v3,  v4,  v5,  v6,  v7 := m[0], m[1], m[2], m[3], m[4], m[5], m[6], m[7]
   v8, v9, v10, v11, v12, v13, v14, v15 := m[8], m[9], m[10], m[11], m[12], m[13], m[14], m[15]
@@ -135,6 +193,37 @@ func (m *Matrix4) Mul4Mut(m2 *Matrix4) {
   m[15] = v3*m2[12] + v7*m2[13] + v11*m2[14] + v15*m2[15]
 }
 
+// Decompose splits m into translation, rotation and scale, the inverse of
+// Matrix4Compose. Any shear or perspective in m is lost.
+func (m *Matrix4) Decompose() (translation Vec3, rotation Quat, scale Vec3) {
+  translation = Vec3{m[12], m[13], m[14]}
+  x := Vec3{m[0], m[1], m[2]}
+  y := Vec3{m[4], m[5], m[6]}
+  z := Vec3{m[8], m[9], m[10]}
+  scale = Vec3{x.Len(), y.Len(), z.Len()}
+  if mgl32.Mat4(*m).Det() < 0 {
+    // mirrored; flip one axis to make the rotation proper
+    scale[0] = -scale[0]
+  }
+  if scale[0] == 0 || scale[1] == 0 || scale[2] == 0 {
+    return translation, QuatIdentity, scale
+  }
+  x, y, z = x.Mul(1 / scale[0]), y.Mul(1 / scale[1]), z.Mul(1 / scale[2])
+  r := mgl32.Mat4{
+    x[0], x[1], x[2], 0,
+    y[0], y[1], y[2], 0,
+    z[0], z[1], z[2], 0,
+    0, 0, 0, 1,
+  }
+  rotation = Quat(mgl32.Mat4ToQuat(r)).Normalize()
+  return
+}
+
+// Inverse returns the inverse of m, or a zero matrix if m is not invertible
+func (m *Matrix4) Inverse() Matrix4 {
+  return Matrix4(mgl32.Mat4(*m).Inv())
+}
+
 func (m *Matrix4) WithRotation3D(radians, axisX, axisY, axisZ float32) Matrix4 {
   s, c := sin32(radians), cos32(radians)
   x, y, z := axisX, axisY, axisZ
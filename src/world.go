@@ -0,0 +1,262 @@
+package main
+
+
+type World struct {
+  Time     float64       // time passed to the most recent call to Update
+  Clock    Clock         // drives PhaseSimulation at a fixed rate
+  Ents     EntManager
+  Commands CommandBuffer // applied at the end of each phase of Update
+  Names    NameSystem    // entity names, e.g. for FindPath("player/gun")
+  Tags     TagSystem     // entity tags, e.g. for Query.Tagged("enemy")
+  Cameras  CameraSystem  // points of view, e.g. for Renderer.Camera
+  Input    Input         // pointer, wheel and keyboard (see HostEnv.ConnectInput)
+  systems  []System
+
+  components []ComponentType // registered by RegisterComponent
+
+  schedule      [phaseCount][]*scheduledSystem
+  scheduleDirty bool // true when schedule needs to be sorted
+
+  TransformSystem
+}
+
+// System is implemented by systems that hold data for entities and are registered
+// with a World by AddSystem.
+type System interface {
+  // DestroyEnt is called by World.Destroy and should release any data associated
+  // with the entity.
+  DestroyEnt(e Ent)
+}
+
+// Init initializes the world and its built-in systems. Options of Ents, like
+// Ents.GenerationBits, are set before calling Init.
+func (w *World) Init() {
+  w.Ents.Init()
+  w.Clock.Init()
+  w.Commands.Init(w)
+  w.systems = w.systems[:0]
+  w.components = w.components[:0]
+  w.TransformSystem.Init(w)
+  w.AddSystem(&w.TransformSystem)
+  w.Names.Init(w)
+  w.AddSystem(&w.Names)
+  w.Tags.Init(w)
+  w.AddSystem(&w.Tags)
+  w.Cameras.Init(w)
+  w.AddSystem(&w.Cameras)
+  w.RegisterComponent("camera", &w.Cameras.ComponentStore)
+  w.Schedule(PhaseTransform, "transform", &w.TransformSystem)
+}
+
+// AddSystem registers s with the world. Adding a system that is already registered
+// has no effect.
+func (w *World) AddSystem(s System) {
+  for _, s2 := range w.systems {
+    if s2 == s {
+      return
+    }
+  }
+  w.systems = append(w.systems, s)
+}
+
+// ComponentType describes a named component registered with a World
+type ComponentType struct {
+  Name  string
+  Store *ComponentStore
+}
+
+// RegisterComponent makes the components in store known to the world by name.
+// Registered components are included when saving and loading scenes.
+// The component values should implement json.Marshaler, json.Unmarshaler,
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler if they contain
+// unexported fields.
+func (w *World) RegisterComponent(name string, store *ComponentStore) {
+  if w.Component(name) != nil {
+    panicf("World.RegisterComponent: duplicate component name %q", name)
+  }
+  w.components = append(w.components, ComponentType{ Name: name, Store: store })
+}
+
+// Component returns the store of a component registered with name, or nil
+func (w *World) Component(name string) *ComponentStore {
+  for _, c := range w.components {
+    if c.Name == name {
+      return c.Store
+    }
+  }
+  return nil
+}
+
+// Components returns all registered components, in the order they were registered
+func (w *World) Components() []ComponentType {
+  return w.components
+}
+
+// Destroy removes e from all registered systems and then frees e.
+// Returns false if e is not alive.
+func (w *World) Destroy(e Ent) bool {
+  if !w.Ents.IsAlive(e) {
+    return false
+  }
+  for _, s := range w.systems {
+    s.DestroyEnt(e)
+  }
+  w.Ents.Free(e)
+  return true
+}
+
+
+// Update advances the world to time (in seconds) by running all scheduled systems,
+// phase by phase. Commands recorded in w.Commands are applied at the end of each phase.
+//
+// PhaseInput systems run once with time. PhaseSimulation systems run zero or more times,
+// once per fixed tick of w.Clock, with the simulation time of each tick. The remaining
+// phases run once with w.Clock.RenderTime(), the simulation time interpolated by
+// w.Clock.Alpha.
+//
+// Movement accumulated in w.Input is reset when Update returns.
+func (w *World) Update(time float64) {
+  w.Time = time
+  w.Commands.Apply() // changes made outside of Update
+  if w.scheduleDirty {
+    w.sortSchedule()
+  }
+  ticks := w.Clock.Advance(time)
+  for phase, l := range w.schedule {
+    switch Phase(phase) {
+    case PhaseInput:
+      w.runPhase(l, time)
+    case PhaseSimulation:
+      for i := 0; i < ticks; i++ {
+        w.runPhase(l, w.Clock.Tick())
+      }
+    default:
+      w.runPhase(l, w.Clock.RenderTime())
+    }
+  }
+  w.Input.endFrame()
+}
+
+func (w *World) runPhase(l []*scheduledSystem, time float64) {
+  for _, ss := range l {
+    ss.u.Update(time)
+  }
+  w.Commands.Apply()
+}
+
+// -----------------------------------------------------------------------------
+
+// Phase is a stage of World.Update. Phases run in the order they are declared here.
+type Phase int
+const (
+  PhaseInput = Phase(iota)  // process input events
+  PhaseSimulation           // gameplay, physics, etc. Runs at a fixed rate (see Clock)
+  PhaseTransform            // compute absolute transforms
+  PhaseRender               // draw
+  phaseCount
+)
+
+func (p Phase) String() string {
+  switch p {
+  case PhaseInput:      return "PhaseInput"
+  case PhaseSimulation: return "PhaseSimulation"
+  case PhaseTransform:  return "PhaseTransform"
+  case PhaseRender:     return "PhaseRender"
+  default:              return "(Phase?)"
+  }
+}
+
+// Updater is implemented by systems that are run by World.Update
+type Updater interface {
+  Update(time float64)
+}
+
+type scheduledSystem struct {
+  name  string
+  u     Updater
+  phase Phase
+  after []string
+}
+
+// Schedule registers u to be run by World.Update during phase.
+// name identifies the system and after lists the names of systems that must run
+// before u. Systems without ordering constraints run in the order they were scheduled.
+func (w *World) Schedule(phase Phase, name string, u Updater, after ...string) {
+  if phase < 0 || phase >= phaseCount {
+    panicf("World.Schedule: invalid phase %d", phase)
+  }
+  if w.findScheduled(name) != nil {
+    panicf("World.Schedule: duplicate system name %q", name)
+  }
+  w.schedule[phase] = append(w.schedule[phase], &scheduledSystem{
+    name:  name,
+    u:     u,
+    phase: phase,
+    after: after,
+  })
+  w.scheduleDirty = true
+}
+
+// Unschedule removes the system with the given name from the schedule.
+// Returns false if no such system is scheduled.
+func (w *World) Unschedule(name string) bool {
+  for phase, l := range w.schedule {
+    for i, ss := range l {
+      if ss.name == name {
+        w.schedule[phase] = append(l[:i], l[i+1:]...)
+        return true
+      }
+    }
+  }
+  return false
+}
+
+func (w *World) findScheduled(name string) *scheduledSystem {
+  for _, l := range w.schedule {
+    for _, ss := range l {
+      if ss.name == name {
+        return ss
+      }
+    }
+  }
+  return nil
+}
+
+// sortSchedule orders the systems of each phase so that every system runs after the
+// systems listed in its "after" list. Panics on unknown names and cycles.
+func (w *World) sortSchedule() {
+  for phase, l := range w.schedule {
+    sorted := make([]*scheduledSystem, 0, len(l))
+    state := make(map[*scheduledSystem]int, len(l)) // 1 = visiting, 2 = done
+    var visit func(ss *scheduledSystem)
+    visit = func(ss *scheduledSystem) {
+      switch state[ss] {
+      case 1:
+        panicf("World.Schedule: dependency cycle involving %q", ss.name)
+      case 2:
+        return
+      }
+      state[ss] = 1
+      for _, name := range ss.after {
+        dep := w.findScheduled(name)
+        if dep == nil {
+          panicf("World.Schedule: %q depends on unknown system %q", ss.name, name)
+        }
+        if dep.phase > ss.phase {
+          panicf("World.Schedule: %q (%s) can not run after %q (%s)",
+            ss.name, ss.phase, dep.name, dep.phase)
+        }
+        if dep.phase == ss.phase {
+          visit(dep)
+        }
+      }
+      state[ss] = 2
+      sorted = append(sorted, ss)
+    }
+    for _, ss := range l {
+      visit(ss)
+    }
+    w.schedule[phase] = sorted
+  }
+  w.scheduleDirty = false
+}